@@ -0,0 +1,132 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// amount is a decimal number stored as an integer number of units of
+// 10^-scale, so that values like "12.50" keep their exact representation.
+type amount struct {
+	units int64
+	scale int
+}
+
+// parseAmount parses a plain decimal number like "-1234.56" or "+3".
+func parseAmount(s string) (amount, error) {
+	str := strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		neg = str[0] == '-'
+		str = str[1:]
+	}
+	intPart, fracPart := str, ""
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		intPart, fracPart = str[:i], str[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return amount{}, fmt.Errorf("invalid amount %q", s)
+	}
+	var a amount
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return amount{}, fmt.Errorf("invalid amount %q", s)
+		}
+		a.units = a.units*10 + int64(c-'0')
+	}
+	a.scale = len(fracPart)
+	if neg {
+		a.units = -a.units
+	}
+	return a, nil
+}
+
+func (a amount) String() string {
+	units := a.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	digits := fmt.Sprintf("%0*d", a.scale+1, units)
+	if a.scale == 0 {
+		return sign + digits
+	}
+	cut := len(digits) - a.scale
+	return sign + digits[:cut] + "." + digits[cut:]
+}
+
+// rescale returns a with the given scale, which must not be smaller than
+// the current one.
+func (a amount) rescale(scale int) amount {
+	for a.scale < scale {
+		a.units *= 10
+		a.scale++
+	}
+	return a
+}
+
+func (a amount) Sign() int {
+	switch {
+	case a.units < 0:
+		return -1
+	case a.units > 0:
+		return 1
+	}
+	return 0
+}
+
+func (a amount) IsZero() bool {
+	return a.units == 0
+}
+
+func (a amount) Neg() amount {
+	return amount{units: -a.units, scale: a.scale}
+}
+
+func (a amount) Add(b amount) amount {
+	if a.scale < b.scale {
+		a = a.rescale(b.scale)
+	} else {
+		b = b.rescale(a.scale)
+	}
+	return amount{units: a.units + b.units, scale: a.scale}
+}
+
+func (a amount) Sub(b amount) amount {
+	return a.Add(b.Neg())
+}
+
+// percent returns pct percent of a, rounded half away from zero to the
+// scale of a.
+func (a amount) percent(pct amount) amount {
+	num := new(big.Int).Mul(big.NewInt(a.units), big.NewInt(pct.units))
+	den := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(pct.scale)), nil)
+	den.Mul(den, big.NewInt(100))
+	return amount{units: roundDiv(num, den), scale: a.scale}
+}
+
+// roundDiv divides num by den, rounding half away from zero.
+func roundDiv(num, den *big.Int) int64 {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	r.Abs(r).Mul(r, big.NewInt(2))
+	if r.Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign()*den.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+// quo returns a divided by b, rounded half away from zero to the scale of a.
+func (a amount) quo(b amount) amount {
+	num := new(big.Int).Mul(big.NewInt(a.units), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(b.scale)), nil))
+	return amount{units: roundDiv(num, big.NewInt(b.units)), scale: a.scale}
+}
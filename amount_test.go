@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in    string
+		units int64
+		scale int
+	}{
+		{"12.50", 1250, 2},
+		{"-12.50", -1250, 2},
+		{"+3", 3, 0},
+		{" 7.1 ", 71, 1},
+		{"-0.00", 0, 2},
+		{".5", 5, 1},
+	}
+	for _, test := range tests {
+		a, err := parseAmount(test.in)
+		if err != nil {
+			t.Errorf("parseAmount(%q): %v", test.in, err)
+			continue
+		}
+		if a.units != test.units || a.scale != test.scale {
+			t.Errorf("parseAmount(%q) = %d/10^%d, want %d/10^%d", test.in, a.units, a.scale, test.units, test.scale)
+		}
+	}
+}
+
+func TestParseAmountInvalid(t *testing.T) {
+	for _, in := range []string{"", "-", "+", ".", "1,234.56", "12.5.0", "abc", "1e3"} {
+		if a, err := parseAmount(in); err == nil {
+			t.Errorf("parseAmount(%q) = %s, want an error", in, a)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"12.50", "12.50"},
+		{"-0.05", "-0.05"},
+		{"+3", "3"},
+		{"-0.00", "0.00"},
+		{"007", "7"},
+	}
+	for _, test := range tests {
+		a, err := parseAmount(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.String(); got != test.want {
+			t.Errorf("parseAmount(%q).String() = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestNegate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"12.50", "-12.50"},
+		{"-12.50", "12.50"},
+		{"+12.50", "-12.50"},
+		{"0.00", "0.00"},
+		{"-0.00", "0.00"},
+		{"+0", "0"},
+		{"", ""},
+		// Not plain numbers, as with -keep-raw-value.
+		{"1,234.56", "-1,234.56"},
+		{"-1,234.56", "1,234.56"},
+		{"+1,234.56", "-1,234.56"},
+	}
+	for _, test := range tests {
+		if got := negate(test.in); got != test.want {
+			t.Errorf("negate(%q) = %q, want %q", test.in, got, test.want)
+		}
+		if test.in != "" && test.in[0] != '+' && !isZero(test.in) {
+			if twice := negate(negate(test.in)); twice != test.in {
+				t.Errorf("negate(negate(%q)) = %q", test.in, twice)
+			}
+		}
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	a, _ := parseAmount("10.5")
+	b, _ := parseAmount("-0.25")
+	if got := a.Add(b).String(); got != "10.25" {
+		t.Errorf("10.5 + -0.25 = %s, want 10.25", got)
+	}
+	if got := a.Sub(b).String(); got != "10.75" {
+		t.Errorf("10.5 - -0.25 = %s, want 10.75", got)
+	}
+	pct, _ := parseAmount("33.3")
+	total, _ := parseAmount("10.00")
+	if got := total.percent(pct).String(); got != "3.33" {
+		t.Errorf("33.3%% of 10.00 = %s, want 3.33", got)
+	}
+	three, _ := parseAmount("3")
+	if got := total.quo(three).String(); got != "3.33" {
+		t.Errorf("10.00 / 3 = %s, want 3.33", got)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// inputLayout describes the csv statements of a bank. Amounts come either
+// from a single signed AmountColumn, or from DebitColumn with a fallback to
+// CreditColumn when AmountColumn is negative.
+type inputLayout struct {
+	Name              string
+	Description       string
+	Header            []string // leading cells of the header row
+	DateColumn        int
+	DateLayout        string // go time layout
+	DescriptionColumn int
+	AmountColumn      int
+	AmountInverted    bool // positive amounts are withdrawals
+	DebitColumn       int
+	CreditColumn      int
+	BalanceColumn     int  // balance after each line, -1 if there's none
+	CurrencyColumn    int  // -1 if there's none, optional in the lines
+	DrCr              bool // amounts with trailing DR and CR indicators
+}
+
+// bankPresets has the built-in layouts, selectable with -bank and detected
+// by their headers otherwise.
+var bankPresets = []inputLayout{
+	{
+		Name:              "aib-credit",
+		Description:       "AIB credit card",
+		Header:            []string{"Masked Card Number", " Posted Transactions Date"},
+		DateColumn:        1,
+		DateLayout:        "02/01/2006",
+		DescriptionColumn: 2,
+		AmountColumn:      -1,
+		DebitColumn:       3,
+		CreditColumn:      4,
+		BalanceColumn:     -1,
+		CurrencyColumn:    5,
+	},
+	{
+		Name:              "aib-debit",
+		Description:       "AIB current account",
+		Header:            []string{"Posted Account", " Posted Transactions Date"},
+		DateColumn:        1,
+		DateLayout:        "02/01/2006",
+		DescriptionColumn: 2,
+		AmountColumn:      -1,
+		DebitColumn:       5,
+		CreditColumn:      6,
+		BalanceColumn:     7,
+		CurrencyColumn:    8,
+	},
+}
+
+// UnmarshalJSON reads the InputFormat of the config, where the columns that
+// are missing are -1, so that only the ones of the statements are needed.
+func (l *inputLayout) UnmarshalJSON(dat []byte) error {
+	type plain inputLayout
+	p := plain{Name: "InputFormat", AmountColumn: -1, DebitColumn: -1, CreditColumn: -1, BalanceColumn: -1, CurrencyColumn: -1}
+	if err := json.Unmarshal(dat, &p); err != nil {
+		return err
+	}
+	*l = inputLayout(p)
+	return nil
+}
+
+// validate checks a layout that doesn't come from the presets.
+func (l *inputLayout) validate() error {
+	switch {
+	case l.DateLayout == "":
+		return errors.New("no DateLayout")
+	case l.DateColumn < 0 || l.DescriptionColumn < 0:
+		return errors.New("negative DateColumn or DescriptionColumn")
+	case l.AmountColumn < 0 && (l.DebitColumn < 0 || l.CreditColumn < 0):
+		return errors.New("no AmountColumn or DebitColumn and CreditColumn")
+	case l.AmountColumn >= 0 && (l.DebitColumn >= 0 || l.CreditColumn >= 0):
+		return errors.New("both AmountColumn and DebitColumn or CreditColumn")
+	}
+	return nil
+}
+
+func bankPreset(name string) (*inputLayout, error) {
+	for i := range bankPresets {
+		if bankPresets[i].Name == name {
+			return &bankPresets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown bank %q, see -list-banks", name)
+}
+
+func listBanks(out io.Writer) error {
+	for _, preset := range bankPresets {
+		if _, err := fmt.Fprintf(out, "%-12s %s\n", preset.Name, preset.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isHeader checks if the line is the header of the layout. The cells are
+// compared without surrounding spaces and byte order marks, which appear in
+// the middle of concatenated files.
+func (l *inputLayout) isHeader(line []string) bool {
+	if len(l.Header) == 0 || len(line) < len(l.Header) {
+		return false
+	}
+	for i, cell := range l.Header {
+		if headerCell(line[i]) != headerCell(cell) {
+			return false
+		}
+	}
+	return true
+}
+
+func headerCell(cell string) string {
+	return strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff"))
+}
+
+// columns returns the minimum number of columns of the lines.
+func (l *inputLayout) columns() int {
+	max := l.DateColumn
+	for _, col := range []int{l.DescriptionColumn, l.AmountColumn, l.DebitColumn, l.CreditColumn, l.BalanceColumn} {
+		if col > max {
+			max = col
+		}
+	}
+	return max + 1
+}
+
+// detectLayout returns the preset whose header is the line, if any.
+func detectLayout(line []string) *inputLayout {
+	for i := range bankPresets {
+		if bankPresets[i].isHeader(line) {
+			return &bankPresets[i]
+		}
+	}
+	return nil
+}
+
+// layoutOverrides replaces single columns and the date layout of the
+// layouts, set by -date-col, -desc-col, -amount-col and -dateformat; -1 and
+// "" keep the ones of the layout.
+type layoutOverrides struct {
+	date        int
+	description int
+	amount      int // a single signed amount, replacing the debit and credit
+	dateLayout  string
+}
+
+// apply returns the layout with the overrides.
+func (c layoutOverrides) apply(l *inputLayout) *inputLayout {
+	if c.date < 0 && c.description < 0 && c.amount < 0 && c.dateLayout == "" {
+		return l
+	}
+	o := *l
+	if c.date >= 0 {
+		o.DateColumn = c.date
+	}
+	if c.description >= 0 {
+		o.DescriptionColumn = c.description
+	}
+	if c.amount >= 0 {
+		o.AmountColumn, o.DebitColumn, o.CreditColumn = c.amount, -1, -1
+	}
+	if c.dateLayout != "" {
+		o.DateLayout = c.dateLayout
+	}
+	return &o
+}
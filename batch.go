@@ -0,0 +1,75 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+// Batch:
+
+// batchJob is a run of bankcsv in a -batch manifest.
+type batchJob struct {
+	SrcAccount string // optional, as in the arguments
+	Config     string
+	Inputs     []string
+	Output     string   // -o of the job, stdout if empty
+	Flags      []string // other flags of the job
+}
+
+// args returns the command line arguments of the job.
+func (job *batchJob) args() []string {
+	args := append([]string{}, job.Flags...)
+	if job.Output != "" {
+		args = append(args, "-o", job.Output)
+	}
+	if job.SrcAccount != "" {
+		args = append(args, job.SrcAccount)
+	}
+	args = append(args, job.Config)
+	return append(args, job.Inputs...)
+}
+
+// runBatch runs the jobs of the manifest in order, each one in its own
+// bankcsv process, reporting the ones that fail. They all run unless
+// failFast, when the first failure stops the batch.
+func runBatch(manifestName string, failFast bool) error {
+	dat, err := ioutil.ReadFile(manifestName)
+	if err != nil {
+		return err
+	}
+	var jobs []batchJob
+	if err := json.Unmarshal(dat, &jobs); err != nil {
+		return fmt.Errorf("%s: %w", manifestName, err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for i, job := range jobs {
+		if job.Config == "" || len(job.Inputs) == 0 {
+			return fmt.Errorf("%s: job %d without Config or Inputs", manifestName, i+1)
+		}
+		cmd := exec.Command(self, job.args()...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			failed++
+			warnf("job %d (%s) failed: %s", i+1, job.Config, err)
+			if failFast {
+				return fmt.Errorf("job %d of %d failed", i+1, len(jobs))
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs failed", failed, len(jobs))
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Beancount:
+
+// beancountOpeningAccount is the default -opening-balance-account of the
+// beancount format, as its account names can't have spaces.
+const beancountOpeningAccount = "Equity:Opening-Balances"
+
+// beancountAccountTypes are the root components of the beancount accounts.
+var beancountAccountTypes = map[string]bool{
+	"Assets": true, "Liabilities": true, "Equity": true, "Income": true, "Expenses": true,
+}
+
+// checkBeancountAccount verifies that the account is a valid beancount
+// account name: a root type followed by components separated by colons,
+// each starting with an uppercase letter or a digit and having only letters,
+// digits and dashes.
+func checkBeancountAccount(account string) error {
+	components := strings.Split(account, ":")
+	if !beancountAccountTypes[components[0]] {
+		return fmt.Errorf("invalid beancount account %q, it must start with Assets, Liabilities, Equity, Income or Expenses", account)
+	}
+	if len(components) == 1 {
+		return fmt.Errorf("invalid beancount account %q, it has no component after its type", account)
+	}
+	for _, component := range components[1:] {
+		for i, c := range component {
+			if i == 0 && !unicode.IsUpper(c) && !unicode.IsDigit(c) {
+				return fmt.Errorf("invalid beancount account %q, its component %q must start with an uppercase letter or a digit", account, component)
+			}
+			if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' {
+				return fmt.Errorf("invalid beancount account %q, its component %q can only have letters, digits and dashes", account, component)
+			}
+		}
+		if component == "" {
+			return fmt.Errorf("invalid beancount account %q, it has an empty component", account)
+		}
+	}
+	return nil
+}
+
+// checkBeancountAccounts verifies the names of the accounts of the config
+// and of the flags that the beancount format writes, as they are output,
+// with their aliases and prefixes. The source account of the arguments is
+// checked when it's known.
+func checkBeancountAccounts(cfg *config, opts *options) error {
+	if opts.openingBalance != "" && opts.openingAccount != "" {
+		if err := checkBeancountAccount(opts.openingAccount); err != nil {
+			return fmt.Errorf("-opening-balance-account: %w", err)
+		}
+	}
+	if opts.roundingAccount != "" {
+		if err := checkBeancountAccount(opts.roundingAccount); err != nil {
+			return fmt.Errorf("-rounding-account: %w", err)
+		}
+	}
+	check := func(account string, where string) error {
+		if account == "" {
+			return nil
+		}
+		if err := checkBeancountAccount(opts.prefixAccount(opts.accountPrefix, cfg.alias(account))); err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+		return nil
+	}
+	for _, descAcc := range cfg.AccountFromDescription {
+		where := fmt.Sprintf("rule %q (%s)", descAcc.name(), descAcc.file)
+		if err := check(descAcc.Account, where); err != nil {
+			return err
+		}
+		for _, sp := range descAcc.Splits {
+			if err := check(sp.Account, where); err != nil {
+				return err
+			}
+		}
+	}
+	if err := check(cfg.DefaultAccount, "DefaultAccount"); err != nil {
+		return err
+	}
+	if err := check(cfg.DefaultDebitAccount, "DefaultDebitAccount"); err != nil {
+		return err
+	}
+	if err := check(cfg.DefaultCreditAccount, "DefaultCreditAccount"); err != nil {
+		return err
+	}
+	for key, account := range cfg.lookup {
+		if err := check(account, fmt.Sprintf("lookup of %q", key)); err != nil {
+			return err
+		}
+	}
+	srcAccounts := []string{cfg.SrcAccount}
+	for _, account := range cfg.SrcAccounts {
+		srcAccounts = append(srcAccounts, account)
+	}
+	for _, account := range srcAccounts {
+		if account == "" {
+			continue
+		}
+		if err := checkBeancountAccount(opts.prefixAccount(opts.srcAccountPrefix, account)); err != nil {
+			return fmt.Errorf("source account: %w", err)
+		}
+	}
+	return nil
+}
+
+// outputBeancountFormat writes a beancount ledger. As in ledger-cli, the
+// amounts are positive for money going into the account, but beancount
+// requires the currency of every amount, the iso dates, and an open
+// directive for each account, written at the end with the date of its
+// first posting.
+type outputBeancountFormat struct {
+	out        *bufio.Writer
+	opening    *transaction // pending opening balance entry
+	comment    bool         // with the header and trailer comments
+	rounding   string       // account of the residuals of the entries that don't balance
+	inputNames []string
+	first      time.Time
+	last       time.Time
+	accounts   []string             // in the order of their first postings
+	opened     map[string]time.Time // date of the first posting of each account
+}
+
+func newOutputBeancountFormat(opts *options, cfg *config, srcAccount string) outputFormat {
+	o := outputBeancountFormat{comment: opts.headerComment, inputNames: opts.inputNames,
+		rounding: opts.roundingAccount, opened: map[string]time.Time{}}
+	if opts.openingBalance != "" {
+		account := opts.openingAccount
+		if account == "" {
+			account = beancountOpeningAccount
+		}
+		o.opening = &transaction{
+			Date:        opts.sinceDate,
+			Description: "Opening Balance",
+			Value:       negate(opts.openingBalance),
+			Currency:    opts.currency,
+			SrcAccount:  srcAccount,
+			Account:     account,
+		}
+	}
+	return &o
+}
+
+func (o *outputBeancountFormat) Init(out io.Writer) error {
+	o.out = bufio.NewWriter(out)
+	if o.comment {
+		lines := []string{"Generated by bankcsv at " + time.Now().UTC().Format(time.RFC3339)}
+		for _, inputName := range o.inputNames {
+			lines = append(lines, "Input: "+inputName)
+		}
+		for _, line := range lines {
+			if err := o.printf("; %s\n", line); err != nil {
+				return err
+			}
+		}
+		if err := o.printf("\n"); err != nil {
+			return err
+		}
+	}
+	if o.opening != nil && !o.opening.Date.IsZero() {
+		return o.addOpening(o.opening.Date)
+	}
+	return nil
+}
+
+// printf writes to the output.
+func (o *outputBeancountFormat) printf(format string, args ...interface{}) error {
+	if _, err := fmt.Fprintf(o.out, format, args...); err != nil {
+		return fmt.Errorf("error writing beancount entry: %w", err)
+	}
+	return nil
+}
+
+// addOpening writes the opening balance entry, if it's still pending.
+func (o *outputBeancountFormat) addOpening(date time.Time) error {
+	if o.opening == nil {
+		return nil
+	}
+	opening := o.opening
+	o.opening = nil
+	opening.Date = date
+	return o.Add(opening)
+}
+
+// beancountString quotes a string of a directive.
+func beancountString(s string) string {
+	return `"` + strings.Replace(strings.Replace(s, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+func (o *outputBeancountFormat) Add(t *transaction) error {
+	if err := o.addOpening(t.Date); err != nil {
+		return err
+	}
+	if t.Currency == "" {
+		return fmt.Errorf("%s:%d: the beancount format needs the currency of the transactions, from the input or -currency", t.File, t.Line)
+	}
+	if o.first.IsZero() || t.Date.Before(o.first) {
+		o.first = t.Date
+	}
+	if t.Date.After(o.last) {
+		o.last = t.Date
+	}
+	header := t.Date.Format("2006-01-02") + " *"
+	if t.Payee != "" {
+		header += " " + beancountString(t.Payee)
+	}
+	if err := o.printf("%s %s\n", header, beancountString(t.Description)); err != nil {
+		return err
+	}
+	if t.ID != "" {
+		if err := o.printf("  id: %s\n", beancountString(t.ID)); err != nil {
+			return err
+		}
+	}
+	legs := append([]posting{{Account: t.SrcAccount, Value: t.Value}}, t.dstLegs()...)
+	if o.rounding != "" {
+		legs = appendResidual(legs, o.rounding)
+	}
+	for i, leg := range legs {
+		if opened, ok := o.opened[leg.Account]; !ok {
+			o.accounts = append(o.accounts, leg.Account)
+			o.opened[leg.Account] = t.Date
+		} else if t.Date.Before(opened) {
+			o.opened[leg.Account] = t.Date
+		}
+		value := negate(leg.Value) + " " + t.Currency
+		if i == 0 && t.OrigCurrency != "" {
+			value = fmt.Sprintf("%s %s @@ %s %s", negate(t.OrigValue), t.OrigCurrency, strings.TrimPrefix(t.Value, "-"), t.Currency)
+		}
+		if err := o.printf("  %-36s  %12s\n", leg.Account, value); err != nil {
+			return err
+		}
+	}
+	return o.printf("\n")
+}
+
+func (o *outputBeancountFormat) Finish() error {
+	for _, account := range o.accounts {
+		if err := o.printf("%s open %s\n", o.opened[account].Format("2006-01-02"), account); err != nil {
+			return err
+		}
+	}
+	if o.comment && !o.first.IsZero() {
+		if err := o.printf("\n; Transactions from %s to %s\n", o.first.Format("2006-01-02"), o.last.Format("2006-01-02")); err != nil {
+			return err
+		}
+	}
+	return o.out.Flush()
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckBeancountAccount(t *testing.T) {
+	tests := []struct {
+		account string
+		valid   bool
+	}{
+		{"Assets:Checking", true},
+		{"Equity:Opening-Balances", true},
+		{"Expenses:Food:2018", true},
+		{"Liabilities:Card:Visa-1234", true},
+		{"Assets", false},
+		{"Asset:Checking", false},
+		{"Assets:checking", false},
+		{"Equity:Opening Balances", false},
+		{"Expenses:Food_Out", false},
+		{"Expenses::Food", false},
+		{"Expenses:Food:", false},
+		{"Expenses:-Food", false},
+	}
+	for _, test := range tests {
+		if err := checkBeancountAccount(test.account); (err == nil) != test.valid {
+			t.Errorf("%q: got %v, want valid %v", test.account, err, test.valid)
+		}
+	}
+}
+
+func TestCheckBeancountAccounts(t *testing.T) {
+	tests := []struct {
+		cfg   config
+		opts  options
+		valid bool
+	}{
+		{config{AccountFromDescription: []accountFromDescription{{Account: "Expenses:Groceries"}}}, options{}, true},
+		{config{AccountFromDescription: []accountFromDescription{{Account: "Groceries"}}}, options{}, false},
+		// The accounts are checked as they are output.
+		{config{AccountFromDescription: []accountFromDescription{{Account: "Groceries"}}},
+			options{accountPrefix: "Expenses", accountSeparator: ":"}, true},
+		{config{AccountFromDescription: []accountFromDescription{{Account: "food"}},
+			Aliases: map[string]string{"food": "Expenses:Food"}}, options{}, true},
+		{config{AccountFromDescription: []accountFromDescription{{Splits: []split{{Account: "Expenses:Food out"}}}}}, options{}, false},
+		{config{DefaultDebitAccount: "Expenses:unknown"}, options{}, false},
+		{config{SrcAccount: "Assets:checking"}, options{}, false},
+		{config{}, options{roundingAccount: "Equity:Rounding"}, true},
+		{config{}, options{roundingAccount: "Rounding"}, false},
+		{config{}, options{openingBalance: "10", openingAccount: "Equity:Opening Balances"}, false},
+	}
+	for i, test := range tests {
+		if err := checkBeancountAccounts(&test.cfg, &test.opts); (err == nil) != test.valid {
+			t.Errorf("test %d: got %v, want valid %v", i, err, test.valid)
+		}
+	}
+}
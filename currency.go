@@ -0,0 +1,59 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// iso4217 has the active ISO 4217 currency codes.
+var iso4217 = map[string]bool{}
+
+func init() {
+	codes := "AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BOV " +
+		"BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU CRC CUC CUP CVE CZK " +
+		"DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HRK " +
+		"HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK " +
+		"LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD " +
+		"NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR " +
+		"SDG SEK SGD SHP SLL SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS " +
+		"UAH UGX USD USN UYI UYU UYW UZS VES VND VUV WST XAF XAG XAU XBA XBB XBC XBD XCD XDR " +
+		"XOF XPD XPF XPT XSU XTS XUA XXX YER ZAR ZMW ZWL"
+	for _, code := range strings.Fields(codes) {
+		iso4217[code] = true
+	}
+}
+
+// normalizeCurrency returns the currency as an uppercase ISO 4217 code, and
+// an error if it's not one.
+func normalizeCurrency(currency string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(currency))
+	if !iso4217[code] {
+		return code, fmt.Errorf("invalid currency %q, not an ISO 4217 code", currency)
+	}
+	return code, nil
+}
+
+// currencySymbol renders amounts with a symbol in the text formats, set by
+// -currency-symbol and -symbol-position.
+type currencySymbol struct {
+	symbol string
+	after  bool
+}
+
+// format returns the amount with the symbol, like -$12.50 or -12.50 EUR.
+func (c currencySymbol) format(value string) string {
+	if c.symbol == "" || value == "" {
+		return value
+	}
+	if c.after {
+		return value + " " + c.symbol
+	}
+	if strings.HasPrefix(value, "-") {
+		return "-" + c.symbol + value[1:]
+	}
+	return c.symbol + value
+}
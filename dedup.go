@@ -0,0 +1,150 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dedupFields are the fields that -dedup-by can use in the key.
+var dedupFields = []string{"id", "date", "description", "value", "type", "balance", "currency"}
+
+// parseDedupBy parses the comma-separated list of -dedup-by.
+func parseDedupBy(list string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		known := false
+		for _, f := range dedupFields {
+			known = known || f == field
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field %q, must be one of %s", field, strings.Join(dedupFields, ","))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// parseDedupWindow parses the -dedup-window, a number of days like 7d or a
+// go duration like 72h.
+func parseDedupWindow(window string) (time.Duration, error) {
+	if strings.HasSuffix(window, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(window, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", window)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(window)
+	if err == nil && d < 0 {
+		err = fmt.Errorf("negative window %q", window)
+	}
+	return d, err
+}
+
+// dedupSeen is a transaction seen by the deduper.
+type dedupSeen struct {
+	date time.Time
+	file string
+}
+
+// deduper detects the transactions whose key, made of the dedup fields, was
+// already seen. With a window, the dates aren't part of the key, and the
+// transactions are only duplicates of the ones seen within the window;
+// without one, the dates only matter when they are one of the fields. With
+// inputs, for -dedup-scope inputs, only the repeats of the transactions of
+// other inputs are duplicates, as many as there were in one of them, so that
+// the identical transactions of a statement, like two equal purchases in a
+// day, are kept.
+type deduper struct {
+	fields []string
+	window time.Duration
+	inputs bool
+	seen   map[string][]dedupSeen // of each key
+}
+
+func newDeduper(fields []string, window time.Duration, inputs bool) *deduper {
+	if window > 0 {
+		var keyFields []string
+		for _, field := range fields {
+			if field != "date" {
+				keyFields = append(keyFields, field)
+			}
+		}
+		fields = keyFields
+	}
+	return &deduper{fields: fields, window: window, inputs: inputs, seen: map[string][]dedupSeen{}}
+}
+
+// key returns the key of the transaction. Its id is the one counted in its
+// input only, as the counters of the run depend on the inputs before it,
+// so that the repeated transactions of overlapping inputs have the same
+// ids.
+func (d *deduper) key(t *transaction) string {
+	values := make([]string, len(d.fields))
+	for i, field := range d.fields {
+		if field == "id" && t.fileID != "" {
+			values[i] = t.fileID
+			continue
+		}
+		values[i] = transactionField(t, field, "2006-01-02")
+	}
+	return strings.Join(values, "\x00")
+}
+
+// duplicate checks if the transaction was already seen, and records it.
+// Only the ones that are not duplicates are recorded, unless counting the
+// transactions of each input.
+func (d *deduper) duplicate(t *transaction) bool {
+	key := d.key(t)
+	counts := map[string]int{} // of the transactions within the window, by input
+	for _, seen := range d.seen[key] {
+		diff := t.Date.Sub(seen.date)
+		if d.window == 0 || (diff <= d.window && diff >= -d.window) {
+			counts[seen.file]++
+		}
+	}
+	duplicate := false
+	for file, count := range counts {
+		if !d.inputs || (file != t.File && count > counts[t.File]) {
+			duplicate = true
+		}
+	}
+	if !duplicate || d.inputs {
+		d.seen[key] = append(d.seen[key], dedupSeen{date: t.Date, file: t.File})
+	}
+	return duplicate
+}
+
+// keepLast skips the transactions followed by a duplicate, for -dedup-keep
+// last, keeping the order of the others. All the transactions are buffered,
+// as the last duplicate can be in the last input.
+func (d *deduper) keepLast(in <-chan *transaction, duplicates *int) <-chan *transaction {
+	out := make(chan *transaction)
+	go func() {
+		defer close(out)
+		var all []*transaction
+		for t := range in {
+			all = append(all, t)
+		}
+		keep := make([]bool, len(all))
+		for i := len(all) - 1; i >= 0; i-- {
+			keep[i] = !d.duplicate(all[i])
+		}
+		for i, t := range all {
+			if !keep[i] {
+				infof("%s:%d: skipping duplicate of %s", t.File, t.Line, t.Description)
+				*duplicates++
+				continue
+			}
+			out <- t
+		}
+	}()
+	return out
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeStatements writes the aib-debit statements with the lines, returning
+// their names.
+func writeStatements(t *testing.T, statements ...[]string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var names []string
+	for i, lines := range statements {
+		content := "Posted Account, Posted Transactions Date, Description1, Description2, Description3, Debit Amount, Credit Amount, Balance, Posted Currency, Transaction Type\n"
+		for _, line := range lines {
+			content += line + "\n"
+		}
+		name := filepath.Join(dir, string(rune('a'+i))+".csv")
+		if err := ioutil.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestDedupByIDOverlappingInputs(t *testing.T) {
+	names := writeStatements(t, []string{
+		`"1",01/03/2018,"TESCO","","",12.50,,,EUR,Debit`,
+		`"1",02/03/2018,"COFFEE","","",3.00,,,EUR,Debit`,
+		`"1",02/03/2018,"COFFEE","","",3.00,,,EUR,Debit`,
+	}, []string{
+		`"1",02/03/2018,"COFFEE","","",3.00,,,EUR,Debit`,
+		`"1",02/03/2018,"COFFEE","","",3.00,,,EUR,Debit`,
+		`"1",03/03/2018,"SALARY","","",,1000.00,,EUR,Credit`,
+	})
+	for _, inputs := range []bool{false, true} {
+		var errs pipelineError
+		d := newDeduper([]string{"id"}, 0, inputs)
+		var kept []string
+		for tr := range inputsParse(names, parserOptions(), &errs) {
+			if !d.duplicate(tr) {
+				kept = append(kept, tr.ID+" "+tr.Description)
+			}
+		}
+		if err := errs.get(); err != nil {
+			t.Fatal(err)
+		}
+		// The ids of the run continue across the inputs, the ones of
+		// the second input aren't the ones of the first.
+		want := []string{"2018030101 TESCO", "2018030201 COFFEE", "2018030202 COFFEE", "2018030301 SALARY"}
+		if len(kept) != len(want) {
+			t.Fatalf("inputs scope %v: kept %q, want %q", inputs, kept, want)
+		}
+		for i := range want {
+			if kept[i] != want[i] {
+				t.Errorf("inputs scope %v: kept %q, want %q", inputs, kept, want)
+				break
+			}
+		}
+	}
+}
+
+func TestDedupWithoutDate(t *testing.T) {
+	names := writeStatements(t, []string{
+		`"1",01/03/2018,"TESCO","","",12.50,,,EUR,Debit`,
+		`"1",05/03/2018,"TESCO","","",12.50,,,EUR,Debit`,
+		`"1",20/03/2018,"TESCO","","",12.50,,,EUR,Debit`,
+		`"1",20/03/2018,"TESCO","","",9.00,,,EUR,Debit`,
+	})
+	tests := []struct {
+		fields []string
+		window time.Duration
+		want   []string
+	}{
+		{[]string{"date", "description", "value"}, 0, []string{"01/03 12.50", "05/03 12.50", "20/03 12.50", "20/03 9.00"}},
+		{[]string{"description", "value"}, 0, []string{"01/03 12.50", "20/03 9.00"}},
+		{[]string{"description", "value"}, 7 * 24 * time.Hour, []string{"01/03 12.50", "20/03 12.50", "20/03 9.00"}},
+	}
+	for _, test := range tests {
+		var errs pipelineError
+		d := newDeduper(test.fields, test.window, false)
+		var kept []string
+		for tr := range inputsParse(names, parserOptions(), &errs) {
+			if !d.duplicate(tr) {
+				kept = append(kept, tr.Date.Format("02/01")+" "+strings.TrimPrefix(tr.Value, "-"))
+			}
+		}
+		if err := errs.get(); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(kept, ", ") != strings.Join(test.want, ", ") {
+			t.Errorf("fields %q, window %v: kept %q, want %q", test.fields, test.window, kept, test.want)
+		}
+	}
+}
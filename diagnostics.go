@@ -0,0 +1,71 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// diagnostic is a message about the processing, with the input location and
+// transaction it refers to when there is one.
+type diagnostic struct {
+	Time        string `json:"time"`
+	Level       string `json:"level"`
+	Msg         string `json:"msg"`
+	File        string `json:"file,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// logJSON makes diagnostics be emitted as json objects, one per line, set by
+// -log-format json.
+var logJSON bool
+
+// verbose enables the informational diagnostics, set by -v.
+var verbose bool
+
+func emit(d diagnostic) {
+	if !logJSON {
+		if d.File != "" {
+			log.Printf("%s:%d: %s", d.File, d.Line, d.Msg)
+		} else {
+			log.Print(d.Msg)
+		}
+		return
+	}
+	d.Time = time.Now().Format(time.RFC3339)
+	dat, err := json.Marshal(d)
+	if err != nil {
+		log.Print(d.Msg)
+		return
+	}
+	fmt.Fprintln(log.Writer(), string(dat)) // nolint: errcheck
+}
+
+// warnf emits a warning.
+func warnf(format string, args ...interface{}) {
+	emit(diagnostic{Level: "warning", Msg: fmt.Sprintf(format, args...)})
+}
+
+// infof emits an informational message when verbose.
+func infof(format string, args ...interface{}) {
+	if verbose {
+		emit(diagnostic{Level: "info", Msg: fmt.Sprintf(format, args...)})
+	}
+}
+
+// warnTransactionf emits a warning about a transaction.
+func warnTransactionf(t *transaction, format string, args ...interface{}) {
+	emit(diagnostic{
+		Level:       "warning",
+		Msg:         fmt.Sprintf(format, args...),
+		File:        t.File,
+		Line:        t.Line,
+		Description: t.Description,
+	})
+}
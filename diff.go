@@ -0,0 +1,105 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Diff:
+
+// csvEntries splits a csv output in the rows of each transaction id, that
+// are the row with the id and the balancing rows without one after it. It
+// returns the rows of each id joined and the ids in order.
+func csvEntries(dat []byte) (map[string]string, []string, error) {
+	r := csv.NewReader(bytes.NewReader(dat))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return map[string]string{}, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	col := -1
+	for i, name := range header {
+		if headerCell(name) == "id" {
+			col = i
+		}
+	}
+	if col < 0 {
+		return nil, nil, errors.New("csv without an id column")
+	}
+	entries := map[string]string{}
+	var ids []string
+	id := ""
+	for {
+		line, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if col < len(line) && line[col] != "" {
+			id = line[col]
+		}
+		if _, ok := entries[id]; !ok {
+			ids = append(ids, id)
+		}
+		entries[id] += csvJoin(line) + "\n"
+	}
+	return entries, ids, nil
+}
+
+// writeDiff prints the number of transactions added, removed and changed in
+// the output of the run relative to the existing output file, and each one
+// of them when detailed.
+func writeDiff(out io.Writer, existingName string, output []byte, detailed bool) error {
+	dat, err := ioutil.ReadFile(existingName)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	oldEntries, oldIDs, err := csvEntries(dat)
+	if err != nil {
+		return fmt.Errorf("%s: %w", existingName, err)
+	}
+	newEntries, newIDs, err := csvEntries(output)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	added, removed, changed := 0, 0, 0
+	for _, id := range newIDs {
+		old, ok := oldEntries[id]
+		switch {
+		case !ok:
+			added++
+			lines = append(lines, "+ "+id)
+		case old != newEntries[id]:
+			changed++
+			lines = append(lines, "~ "+id)
+		}
+	}
+	for _, id := range oldIDs {
+		if _, ok := newEntries[id]; !ok {
+			removed++
+			lines = append(lines, "- "+id)
+		}
+	}
+	if detailed && len(lines) > 0 {
+		if _, err := fmt.Fprintln(out, strings.Join(lines, "\n")); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(out, "added %d, removed %d, changed %d, unchanged %d\n",
+		added, removed, changed, len(newIDs)-added-changed)
+	return err
+}
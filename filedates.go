@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// File dates:
+
+// fileDates has the periods implied by the names of the inputs, like
+// statement-2018-03.csv, from the year and month groups of the
+// -date-from-filename regex; without a month group the period is the year.
+// The periods start and end at the midnights of the location of the dates
+// of the inputs.
+type fileDates struct {
+	regex    *regexp.Regexp
+	location *time.Location
+	periods  map[string][2]time.Time // start and end of the period of each file
+}
+
+func newFileDates(regex string, location *time.Location) (*fileDates, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	if re.SubexpIndex("year") < 0 {
+		return nil, errors.New("the regex has no year group, like (?P<year>\\d{4})")
+	}
+	return &fileDates{regex: re, location: location, periods: map[string][2]time.Time{}}, nil
+}
+
+// period returns the period of the input file, false if its name doesn't
+// have one.
+func (f *fileDates) period(fileName string) ([2]time.Time, bool) {
+	if period, ok := f.periods[fileName]; ok {
+		return period, !period[0].IsZero()
+	}
+	var period [2]time.Time
+	m := f.regex.FindStringSubmatch(filepath.Base(fileName))
+	if m != nil {
+		year, err := strconv.Atoi(m[f.regex.SubexpIndex("year")])
+		month := 0
+		if i := f.regex.SubexpIndex("month"); err == nil && i >= 0 && m[i] != "" {
+			month, err = strconv.Atoi(m[i])
+		}
+		switch {
+		case err != nil || month > 12:
+			warnf("%s: no valid period in the name for -date-from-filename", fileName)
+		case month == 0:
+			period[0] = time.Date(year, time.January, 1, 0, 0, 0, 0, f.location)
+			period[1] = period[0].AddDate(1, 0, 0)
+		default:
+			period[0] = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, f.location)
+			period[1] = period[0].AddDate(0, 1, 0)
+		}
+	}
+	f.periods[fileName] = period
+	return period, !period[0].IsZero()
+}
+
+// contains checks if the transaction is in the period of its input file,
+// when it has one.
+func (f *fileDates) contains(t *transaction) bool {
+	period, ok := f.period(t.File)
+	return !ok || (!t.Date.Before(period[0]) && t.Date.Before(period[1]))
+}
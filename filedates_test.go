@@ -0,0 +1,38 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileDatesLocation(t *testing.T) {
+	for _, name := range []string{"UTC", "Asia/Tokyo", "America/New_York"} {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skip(err)
+		}
+		f, err := newFileDates(`statement-(?P<year>\d{4})-(?P<month>\d{2})`, location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			day  time.Time
+			want bool
+		}{
+			{time.Date(2018, 3, 1, 0, 0, 0, 0, location), true},
+			{time.Date(2018, 3, 31, 23, 59, 0, 0, location), true},
+			{time.Date(2018, 2, 28, 23, 59, 0, 0, location), false},
+			{time.Date(2018, 4, 1, 0, 0, 0, 0, location), false},
+		}
+		for _, test := range tests {
+			tr := transaction{Date: test.day, File: "statement-2018-03.csv"}
+			if got := f.contains(&tr); got != test.want {
+				t.Errorf("%s: contains(%s) = %v, want %v", name, test.day, got, test.want)
+			}
+		}
+	}
+}
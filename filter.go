@@ -0,0 +1,273 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter:
+
+// filterExpr is a parsed -filter expression, like
+// `value > 100 && account =~ "^Expenses"`.
+type filterExpr interface {
+	match(t *transaction) bool
+}
+
+type filterAnd struct{ a, b filterExpr }
+type filterOr struct{ a, b filterExpr }
+type filterNot struct{ a filterExpr }
+
+func (f filterAnd) match(t *transaction) bool { return f.a.match(t) && f.b.match(t) }
+func (f filterOr) match(t *transaction) bool  { return f.a.match(t) || f.b.match(t) }
+func (f filterNot) match(t *transaction) bool { return !f.a.match(t) }
+
+// filterFieldKinds has the fields of the expressions and how they are
+// compared: as numbers, dates or strings.
+var filterFieldKinds = map[string]string{
+	"value":       "number",
+	"balance":     "number",
+	"date":        "date",
+	"id":          "string",
+	"description": "string",
+	"account":     "string",
+	"srcaccount":  "string",
+	"type":        "string",
+	"payee":       "string",
+	"currency":    "string",
+}
+
+// filterCompare compares a field of the transactions with a literal, or
+// matches it against a regex with =~ and !~.
+type filterCompare struct {
+	field  string
+	op     string
+	number amount
+	date   time.Time
+	text   string
+	regex  *regexp.Regexp
+}
+
+func (f *filterCompare) match(t *transaction) bool {
+	text := transactionField(t, f.field, "2006-01-02")
+	if f.regex != nil {
+		return f.regex.MatchString(text) == (f.op == "=~")
+	}
+	c := 0
+	switch filterFieldKinds[f.field] {
+	case "number":
+		number, err := parseAmount(text)
+		if err != nil {
+			return false
+		}
+		c = number.Sub(f.number).Sign()
+	case "date":
+		switch {
+		case t.Date.Before(f.date):
+			c = -1
+		case t.Date.After(f.date):
+			c = 1
+		}
+	default:
+		c = strings.Compare(text, f.text)
+	}
+	switch f.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// filterOps are the comparison operators, the two-character ones first.
+var filterOps = []string{"==", "!=", "<=", ">=", "=~", "!~", "<", ">"}
+
+// filterTokenize splits an expression in parentheses, operators, quoted
+// strings and words.
+func filterTokenize(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, expr[i:i+1])
+			i++
+		case strings.HasPrefix(expr[i:], "&&") || strings.HasPrefix(expr[i:], "||"):
+			tokens = append(tokens, expr[i:i+2])
+			i += 2
+		case c == '"':
+			j := i + 1
+			for ; j < len(expr) && expr[j] != '"'; j++ {
+				if expr[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at %d", i+1)
+			}
+			tokens = append(tokens, expr[i:j+1])
+			i = j + 1
+		default:
+			op := ""
+			for _, o := range filterOps {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" && c == '!' {
+				op = "!"
+			}
+			if op != "" {
+				tokens = append(tokens, op)
+				i += len(op)
+				continue
+			}
+			j := i
+			for j < len(expr) && strings.IndexByte(" \t()&|=!<>~\"", expr[j]) < 0 {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i+1)
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser of the expressions, with ! over
+// && over ||.
+type filterParser struct {
+	tokens   []string
+	pos      int
+	location *time.Location // of the dates, from -tz
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *filterParser) or() (filterExpr, error) {
+	a, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.next()
+		var b filterExpr
+		if b, err = p.and(); err == nil {
+			a = filterOr{a, b}
+		}
+	}
+	return a, err
+}
+
+func (p *filterParser) and() (filterExpr, error) {
+	a, err := p.not()
+	for err == nil && p.peek() == "&&" {
+		p.next()
+		var b filterExpr
+		if b, err = p.not(); err == nil {
+			a = filterAnd{a, b}
+		}
+	}
+	return a, err
+}
+
+func (p *filterParser) not() (filterExpr, error) {
+	if p.peek() == "!" {
+		p.next()
+		a, err := p.not()
+		return filterNot{a}, err
+	}
+	if p.peek() == "(" {
+		p.next()
+		a, err := p.or()
+		if err == nil && p.next() != ")" {
+			err = fmt.Errorf("missing )")
+		}
+		return a, err
+	}
+	return p.compare()
+}
+
+func (p *filterParser) compare() (filterExpr, error) {
+	field := p.next()
+	kind, ok := filterFieldKinds[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	f := &filterCompare{field: field, op: p.next()}
+	valid := false
+	for _, op := range filterOps {
+		valid = valid || f.op == op
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid operator %q after %s", f.op, field)
+	}
+	literal := p.next()
+	if literal == "" {
+		return nil, fmt.Errorf("missing value after %s %s", field, f.op)
+	}
+	if strings.HasPrefix(literal, `"`) {
+		var err error
+		if literal, err = strconv.Unquote(literal); err != nil {
+			return nil, fmt.Errorf("invalid string %s: %w", p.tokens[p.pos-1], err)
+		}
+	}
+	var err error
+	switch {
+	case f.op == "=~" || f.op == "!~":
+		f.regex, err = regexp.Compile(literal)
+	case kind == "number":
+		f.number, err = parseAmount(literal)
+	case kind == "date":
+		f.date, err = time.ParseInLocation("2006-01-02", literal, p.location)
+	default:
+		f.text = literal
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid value of %s: %w", field, err)
+	}
+	return f, nil
+}
+
+// parseFilter parses a -filter expression, with its dates in the location.
+func parseFilter(expr string, location *time.Location) (filterExpr, error) {
+	tokens, err := filterTokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := filterParser{tokens: tokens, location: location}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	}
+	return f, nil
+}
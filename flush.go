@@ -0,0 +1,121 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Flush:
+
+// flusher is an output format or file that can write what it has buffered
+// before the end.
+type flusher interface {
+	flush() error
+}
+
+// outputFlushFormat flushes another output format and the output file every
+// -flush-every transactions, so that a reader of a pipe sees them as they
+// are processed.
+type outputFlushFormat struct {
+	outputFormat
+	every int
+	count int
+	file  *outputFile
+}
+
+func newOutputFlushFormat(o outputFormat, every int, file *outputFile) outputFormat {
+	return &outputFlushFormat{outputFormat: o, every: every, file: file}
+}
+
+func (o *outputFlushFormat) Init(out io.Writer) error {
+	return o.outputFormat.Init(out)
+}
+
+func (o *outputFlushFormat) Add(t *transaction) error {
+	if err := o.outputFormat.Add(t); err != nil {
+		return err
+	}
+	o.count++
+	if o.count%o.every != 0 {
+		return nil
+	}
+	if f, ok := o.outputFormat.(flusher); ok {
+		if err := f.flush(); err != nil {
+			return err
+		}
+	}
+	if o.file != nil {
+		return o.file.flush()
+	}
+	return nil
+}
+
+func (o *outputCsvFormat) flush() error {
+	o.outCsv.Flush()
+	return o.outCsv.Error()
+}
+
+// flush does nothing in the table format, that is aligned at the end.
+func (o *outputTableFormat) flush() error { return nil }
+
+func (o *outputLedgerFormat) flush() error {
+	return o.out.Flush()
+}
+
+// flush writes the json objects streamed so far; -pretty buffers the whole
+// array until the end.
+func (o *outputJSONFormat) flush() error {
+	return o.out.Flush()
+}
+
+func (o *outputBeancountFormat) flush() error {
+	return o.out.Flush()
+}
+
+func (o *outputYNABFormat) flush() error {
+	o.outCsv.Flush()
+	return o.outCsv.Error()
+}
+
+func (o *outputDirFormat) flush() error {
+	for i, format := range o.formats {
+		if f, ok := format.(flusher); ok {
+			if err := f.flush(); err != nil {
+				return err
+			}
+		}
+		if err := o.files[i].flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush flushes the files of the template opened so far.
+func (o *outputTemplateFormat) flush() error {
+	for _, name := range o.names {
+		target := o.targets[name]
+		if f, ok := target.format.(flusher); ok {
+			if err := f.flush(); err != nil {
+				return err
+			}
+		}
+		if err := target.file.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *outputFile) flush() error {
+	if f.gz != nil {
+		if err := f.gz.Flush(); err != nil {
+			return fmt.Errorf("error flushing gzip stream: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFlushTemplate(t *testing.T) {
+	dir := t.TempDir()
+	opts := options{outputName: filepath.Join(dir, "{year}-{month}.ledger"), format: "ledger", outputDateFormat: "2006/01/02"}
+	o := newOutputFlushFormat(newOutputTemplateFormat(&opts, &config{}, "Assets:Checking"), 1, nil)
+	if err := o.Init(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, date := range []time.Time{time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC)} {
+		tr := transaction{Date: date, Description: "TESCO", Value: "12.50", SrcAccount: "Assets:Checking", Account: "Expenses:Groceries"}
+		if err := o.Add(&tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Both files have their transactions before the end.
+	for _, name := range []string{"2018-03.ledger", "2018-04.ledger"} {
+		dat, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dat) == 0 {
+			t.Errorf("%s not flushed", name)
+		}
+	}
+	if err := o.Finish(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Exchange rates:
+
+// exchangeRates has the units of each currency worth one unit of the base
+// currency, that the transactions are converted to.
+type exchangeRates struct {
+	base  string
+	rates map[string]amount
+}
+
+// parseRates parses a json object with the rates by currency, either at the
+// top level or in a "rates" member, as in the responses of the usual apis.
+func parseRates(dat []byte) (map[string]amount, error) {
+	var wrapped struct {
+		Rates map[string]json.Number
+	}
+	if err := json.Unmarshal(dat, &wrapped); err != nil || wrapped.Rates == nil {
+		if err := json.Unmarshal(dat, &wrapped.Rates); err != nil {
+			return nil, err
+		}
+	}
+	rates := map[string]amount{}
+	for currency, number := range wrapped.Rates {
+		rate, err := parseAmount(number.String())
+		if err != nil || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q of %s", number, currency)
+		}
+		rates[strings.ToUpper(currency)] = rate
+	}
+	return rates, nil
+}
+
+// loadRates gets the rates from the -fx-api url once for the run, falling
+// back to the -rates file when the fetch fails.
+func loadRates(opts *options) (*exchangeRates, error) {
+	if opts.fxAPI != "" {
+		url := strings.Replace(opts.fxAPI, "{base}", opts.baseCurrency, -1)
+		dat, err := configFetch(url, opts.fxTimeout)
+		if err == nil {
+			var rates map[string]amount
+			if rates, err = parseRates(dat); err == nil {
+				return &exchangeRates{base: opts.baseCurrency, rates: rates}, nil
+			}
+		}
+		errAPI := fmt.Errorf("fetching the exchange rates: %w", err)
+		if opts.ratesName == "" {
+			return nil, errAPI
+		}
+		warnf("%s, using %s", errAPI, opts.ratesName)
+	}
+	dat, err := ioutil.ReadFile(opts.ratesName)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := parseRates(dat)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", opts.ratesName, err)
+	}
+	return &exchangeRates{base: opts.baseCurrency, rates: rates}, nil
+}
+
+// convert converts the values of the transaction to the base currency; the
+// transactions without a currency are assumed to be in it.
+func (x *exchangeRates) convert(t *transaction) error {
+	if t.Currency == "" || t.Currency == x.base {
+		return nil
+	}
+	rate, ok := x.rates[t.Currency]
+	if !ok {
+		return fmt.Errorf("%s:%d: no exchange rate for %s", t.File, t.Line, t.Currency)
+	}
+	t.OrigValue, t.OrigCurrency = t.Value, t.Currency
+	for _, value := range []*string{&t.Value, &t.Balance} {
+		if *value == "" {
+			continue
+		}
+		a, err := parseAmount(*value)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", t.File, t.Line, err)
+		}
+		if a.scale < 2 {
+			a = a.rescale(2)
+		}
+		*value = a.quo(rate).String()
+	}
+	t.Currency = x.base
+	return nil
+}
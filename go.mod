@@ -1,3 +1,5 @@
 module bankcsv
 
 go 1.15
+
+require golang.org/x/text v0.3.6
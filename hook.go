@@ -0,0 +1,105 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Hook:
+
+// hookAmount is a value written back by the hook, a json number or a
+// string with a number, as the json format writes them with and without
+// -json-numbers.
+type hookAmount string
+
+func (a *hookAmount) UnmarshalJSON(dat []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(dat))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+	text := ""
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = v
+	default:
+		return fmt.Errorf("value %s is not a number", dat)
+	}
+	if _, err := parseAmount(text); err != nil {
+		return err
+	}
+	*a = hookAmount(text)
+	return nil
+}
+
+// hookTransaction is the object written back by the hook; the values are
+// pointers, nil when they're missing or null.
+type hookTransaction struct {
+	jsonTransaction
+	Value  *hookAmount `json:"value"`
+	Splits []struct {
+		Account string      `json:"account"`
+		Value   *hookAmount `json:"value"`
+	} `json:"splits,omitempty"`
+}
+
+// runHook pipes the transaction as a json object, as in the json format,
+// through the -hook shell command, and updates it with the object that the
+// command writes back. The account, splits, description, payee, type, date
+// and value can be changed; an empty account leaves the transaction
+// unmatched. The values must be numbers, and the dates are read in the
+// location of -tz.
+func runHook(command string, t *transaction, dateFormat string, location *time.Location) error {
+	enc := outputJSONFormat{dateFormat: dateFormat}
+	dat, err := enc.encode(t)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(dat)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("running the hook %q on %s: %w", command, t.ID, err)
+	}
+	var ht hookTransaction
+	dec := json.NewDecoder(bytes.NewReader(out))
+	dec.UseNumber()
+	if err := dec.Decode(&ht); err != nil {
+		return fmt.Errorf("parsing the output of the hook %q on %s: %w", command, t.ID, err)
+	}
+	if ht.Value == nil {
+		return fmt.Errorf("the hook %q wrote %s back without a value", command, t.ID)
+	}
+	for i, p := range ht.Splits {
+		if p.Value == nil {
+			return fmt.Errorf("the hook %q wrote %s back without the value of split %d", command, t.ID, i+1)
+		}
+	}
+	date, err := time.ParseInLocation(dateFormat, ht.Date, location)
+	if err != nil {
+		return fmt.Errorf("parsing the date of the hook %q on %s: %w", command, t.ID, err)
+	}
+	t.Date = date
+	t.Description = jsonValueText(ht.Description)
+	t.Value = string(*ht.Value)
+	t.Account = jsonValueText(ht.Account)
+	t.Payee = ht.Payee
+	t.Type = jsonValueText(ht.Type)
+	t.Splits = nil
+	for _, p := range ht.Splits {
+		t.Splits = append(t.Splits, posting{Account: p.Account, Value: string(*p.Value)})
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// JSON:
+
+type jsonPosting struct {
+	Account string      `json:"account"`
+	Value   interface{} `json:"value"`
+}
+
+type jsonTransaction struct {
+	ID          string        `json:"id"`
+	Date        string        `json:"date"`
+	Description interface{}   `json:"description"` // string, or null with -empty-as-null
+	Value       interface{}   `json:"value"`
+	RawValue    string        `json:"raw_value,omitempty"`
+	Balance     interface{}   `json:"balance,omitempty"`
+	Currency    string        `json:"currency,omitempty"`
+	Account     interface{}   `json:"account"`
+	SrcAccount  interface{}   `json:"srcaccount"`
+	Type        interface{}   `json:"type"`
+	Payee       string        `json:"payee,omitempty"`
+	Splits      []jsonPosting `json:"splits,omitempty"`
+	Source      string        `json:"source,omitempty"`
+}
+
+// outputJSONFormat streams the transactions as a json array, or as one json
+// object per line in the jsonl format.
+type outputJSONFormat struct {
+	out        *bufio.Writer
+	dateFormat string
+	lines      bool
+	numbers    bool     // values as json numbers instead of strings
+	fields     []string // keys of the objects, in order, from -fields
+	pretty     bool     // indent the whole array at the end instead of streaming
+	rawValue   bool     // with the raw_value of -keep-raw-value
+	emptyNull  bool     // empty strings as null
+	buffered   []json.RawMessage
+	count      int
+}
+
+func newOutputJSONFormat(opts *options, cfg *config, srcAccount string) outputFormat {
+	return &outputJSONFormat{dateFormat: opts.outputDateFormat, numbers: opts.jsonNumbers, fields: opts.fields,
+		pretty: opts.pretty, rawValue: opts.keepRawValue, emptyNull: opts.emptyAsNull, buffered: []json.RawMessage{}}
+}
+
+func newOutputJSONLinesFormat(opts *options, cfg *config, srcAccount string) outputFormat {
+	return &outputJSONFormat{dateFormat: opts.outputDateFormat, numbers: opts.jsonNumbers, fields: opts.fields, lines: true,
+		rawValue: opts.keepRawValue, emptyNull: opts.emptyAsNull}
+}
+
+func (o *outputJSONFormat) Init(out io.Writer) error {
+	o.out = bufio.NewWriter(out)
+	if !o.lines && !o.pretty {
+		if _, err := o.out.WriteString("["); err != nil {
+			return fmt.Errorf("error writing json: %w", err)
+		}
+	}
+	return nil
+}
+
+// value returns the value as a string or as a json number.
+func (o *outputJSONFormat) value(value string) interface{} {
+	if !o.numbers {
+		return value
+	}
+	a, err := parseAmount(value)
+	if err != nil {
+		warnf("value %q is not a number", value)
+		return value
+	}
+	return json.Number(a.String())
+}
+
+// text returns the text, or nil for null if it's empty with -empty-as-null.
+func (o *outputJSONFormat) text(text string) interface{} {
+	if o.emptyNull && text == "" {
+		return nil
+	}
+	return text
+}
+
+// encodeFields encodes the transaction as an object with the selected fields,
+// in their order.
+func (o *outputJSONFormat) encodeFields(t *transaction) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, field := range o.fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		text := transactionField(t, field, o.dateFormat)
+		value := o.text(text)
+		if (field == "value" || field == "balance") && text != "" {
+			value = o.value(text)
+		}
+		key, _ := json.Marshal(field)
+		dat, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(dat)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// encode encodes the transaction as a json object.
+func (o *outputJSONFormat) encode(t *transaction) ([]byte, error) {
+	if o.fields != nil {
+		return o.encodeFields(t)
+	}
+	jt := jsonTransaction{
+		ID:          t.ID,
+		Date:        t.Date.Format(o.dateFormat),
+		Description: o.text(t.Description),
+		Value:       o.value(t.Value),
+		Currency:    t.Currency,
+		Account:     o.text(t.Account),
+		SrcAccount:  o.text(t.SrcAccount),
+		Type:        o.text(t.Type),
+		Payee:       t.Payee,
+		Source:      t.Source,
+	}
+	if t.Balance != "" {
+		jt.Balance = o.value(t.Balance)
+	}
+	if o.rawValue {
+		jt.RawValue = t.RawValue
+	}
+	for _, p := range t.Splits {
+		jt.Splits = append(jt.Splits, jsonPosting{Account: p.Account, Value: o.value(p.Value)})
+	}
+	return json.Marshal(jt)
+}
+
+func (o *outputJSONFormat) Add(t *transaction) error {
+	dat, err := o.encode(t)
+	if err != nil {
+		return fmt.Errorf("error encoding json: %w", err)
+	}
+	if o.pretty {
+		o.buffered = append(o.buffered, dat)
+		return nil
+	}
+	sep := ""
+	if o.lines {
+		dat = append(dat, '\n')
+	} else if o.count > 0 {
+		sep = ",\n"
+	} else {
+		sep = "\n"
+	}
+	o.count++
+	if _, err := o.out.WriteString(sep); err != nil {
+		return fmt.Errorf("error writing json: %w", err)
+	}
+	if _, err := o.out.Write(dat); err != nil {
+		return fmt.Errorf("error writing json: %w", err)
+	}
+	return nil
+}
+
+func (o *outputJSONFormat) Finish() error {
+	if o.pretty {
+		dat, err := json.MarshalIndent(o.buffered, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding json: %w", err)
+		}
+		if _, err := o.out.Write(append(dat, '\n')); err != nil {
+			return fmt.Errorf("error writing json: %w", err)
+		}
+	} else if !o.lines {
+		if _, err := o.out.WriteString("\n]\n"); err != nil {
+			return fmt.Errorf("error writing json: %w", err)
+		}
+	}
+	return o.out.Flush()
+}
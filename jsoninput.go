@@ -0,0 +1,145 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// JSON input:
+
+// jsonInputFieldNames are the fields of the transactions read from the json
+// inputs, mapped to their keys by -json-fields.
+var jsonInputFieldNames = []string{"date", "description", "amount", "account", "currency"}
+
+// jsonInputFields maps the fields of the transactions to the keys of the
+// objects of the json inputs.
+type jsonInputFields struct {
+	keys     map[string]string
+	deposits bool // positive amounts are deposits
+}
+
+// defaultJSONInputFields has the keys of the plaid transactions.
+func defaultJSONInputFields() map[string]string {
+	return map[string]string{
+		"date":        "date",
+		"description": "name",
+		"amount":      "amount",
+		"account":     "account_id",
+		"currency":    "iso_currency_code",
+	}
+}
+
+// parse parses a comma-separated list of field=key, replacing the keys of
+// the given fields.
+func (f *jsonInputFields) parse(list string) error {
+	for _, item := range strings.Split(list, ",") {
+		eq := strings.Index(item, "=")
+		if eq < 0 {
+			return fmt.Errorf("%q is not a field=key", item)
+		}
+		field, key := strings.TrimSpace(item[:eq]), strings.TrimSpace(item[eq+1:])
+		if _, ok := f.keys[field]; !ok {
+			return fmt.Errorf("unknown field %q, must be one of %s", field, strings.Join(jsonInputFieldNames, ","))
+		}
+		f.keys[field] = key
+	}
+	return nil
+}
+
+// jsonValueText returns a decoded json string or number as text, and ""
+// for the other values, like null.
+func jsonValueText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// jsonText returns a string or number of a json object as text.
+func jsonText(object map[string]interface{}, key string) string {
+	return jsonValueText(object[key])
+}
+
+// parseJSON parses the json exports of aggregators, with -informat json:
+// an array of transactions, or an object with them in its transactions key,
+// as in the responses of plaid. The account ids are the source accounts,
+// which the SrcAccounts of the config can rename.
+func (p *inputParser) parseJSON(inputName string, input io.Reader) error {
+	dat, err := ioutil.ReadAll(bufio.NewReader(input))
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(dat))
+	decoder.UseNumber()
+	var objects []map[string]interface{}
+	if err := decoder.Decode(&objects); err != nil {
+		var response struct {
+			Transactions []map[string]interface{}
+		}
+		decoder = json.NewDecoder(bytes.NewReader(dat))
+		decoder.UseNumber()
+		if err := decoder.Decode(&response); err != nil {
+			return fmt.Errorf("%s: %w", inputName, err)
+		}
+		objects = response.Transactions
+	}
+	keys := p.jsonFields.keys
+	dateLayout := "2006-01-02"
+	if p.overrides.dateLayout != "" {
+		dateLayout = p.overrides.dateLayout
+	}
+	for i, object := range objects {
+		fields := make([]string, len(jsonInputFieldNames))
+		for j, field := range jsonInputFieldNames {
+			fields[j] = jsonText(object, keys[field])
+		}
+		date, err := ymdParse(jsonText(object, keys["date"]), dateLayout, p.location, p.centuryPivot)
+		if err != nil {
+			if err := p.badLine(inputName, i+1, err); err != nil {
+				return err
+			}
+			continue
+		}
+		rawValue := jsonText(object, keys["amount"])
+		value := p.normalizeValue(rawValue)
+		if _, err := parseAmount(value); err != nil {
+			if err := p.badLine(inputName, i+1, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if p.jsonFields.deposits {
+			value = negate(value)
+		}
+		id, fileID := p.nextID(date)
+		t := &transaction{
+			ID:          id,
+			fileID:      fileID,
+			Date:        date,
+			Description: p.description(jsonText(object, keys["description"])),
+			Value:       value,
+			RawValue:    rawValue,
+			Currency:    jsonText(object, keys["currency"]),
+			SrcAccount:  jsonText(object, keys["account"]),
+			Fields:      fields,
+			File:        inputName,
+			Line:        i + 1,
+			timed:       layoutHasClock(dateLayout),
+		}
+		t.Type = valueSign(t)
+		p.out <- parseResult{t: t}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Ledger:
+
+// ledgerOpeningAccount is the default -opening-balance-account of the ledger
+// and hledger journals, which accept spaces in the account names.
+const ledgerOpeningAccount = "Equity:Opening Balances"
+
+// outputLedgerFormat writes a ledger-cli journal, also read by hledger.
+// Ledger amounts are positive for money going into the account, the opposite
+// of the withdrawal values of the transactions.
+type outputLedgerFormat struct {
+	out        *bufio.Writer
+	dateFormat string
+	symbol     currencySymbol
+	opening    *transaction // pending opening balance entry
+	comment    bool         // with the header and trailer comments
+	rounding   string       // account of the residuals of the entries that don't balance
+	inputNames []string
+	first      time.Time
+	last       time.Time
+}
+
+func newOutputLedgerFormat(opts *options, cfg *config, srcAccount string) outputFormat {
+	o := outputLedgerFormat{dateFormat: opts.outputDateFormat, symbol: opts.symbol, comment: opts.headerComment,
+		inputNames: opts.inputNames, rounding: opts.roundingAccount}
+	if opts.openingBalance != "" {
+		account := opts.openingAccount
+		if account == "" {
+			account = ledgerOpeningAccount
+		}
+		o.opening = &transaction{
+			Date:        opts.sinceDate,
+			Description: "Opening Balance",
+			Value:       negate(opts.openingBalance),
+			SrcAccount:  srcAccount,
+			Account:     account,
+		}
+	}
+	return &o
+}
+
+func (o *outputLedgerFormat) Init(out io.Writer) error {
+	o.out = bufio.NewWriter(out)
+	if o.comment {
+		if err := o.writeHeaderComment(); err != nil {
+			return err
+		}
+	}
+	if o.opening != nil && !o.opening.Date.IsZero() {
+		return o.addOpening(o.opening.Date)
+	}
+	return nil
+}
+
+// writeHeaderComment writes the comment with the inputs and the generation
+// time at the start of the journal. The date range is only known at the
+// end, so it's in the trailer comment written by Finish.
+func (o *outputLedgerFormat) writeHeaderComment() error {
+	lines := []string{"Generated by bankcsv at " + time.Now().UTC().Format(time.RFC3339)}
+	for _, inputName := range o.inputNames {
+		lines = append(lines, "Input: "+inputName)
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(o.out, "; %s\n", line); err != nil {
+			return fmt.Errorf("error writing ledger comment: %w", err)
+		}
+	}
+	if _, err := fmt.Fprintln(o.out); err != nil {
+		return fmt.Errorf("error writing ledger comment: %w", err)
+	}
+	return nil
+}
+
+// addOpening writes the opening balance entry, if it's still pending.
+func (o *outputLedgerFormat) addOpening(date time.Time) error {
+	if o.opening == nil {
+		return nil
+	}
+	opening := o.opening
+	o.opening = nil
+	opening.Date = date
+	return o.Add(opening)
+}
+
+func (o *outputLedgerFormat) Add(t *transaction) error {
+	if err := o.addOpening(t.Date); err != nil {
+		return err
+	}
+	if o.first.IsZero() || t.Date.Before(o.first) {
+		o.first = t.Date
+	}
+	if t.Date.After(o.last) {
+		o.last = t.Date
+	}
+	header := t.Date.Format(o.dateFormat)
+	if t.ID != "" {
+		header += " (" + t.ID + ")"
+	}
+	if _, err := fmt.Fprintf(o.out, "%s %s\n", header, t.Description); err != nil {
+		return fmt.Errorf("error writing ledger entry: %w", err)
+	}
+	legs := append([]posting{{Account: t.SrcAccount, Value: t.Value}}, t.dstLegs()...)
+	if o.rounding != "" {
+		legs = appendResidual(legs, o.rounding)
+	}
+	for i, leg := range legs {
+		value := o.symbol.format(negate(leg.Value))
+		if t.OrigCurrency != "" {
+			value = o.converted(t, i, leg)
+		}
+		if _, err := fmt.Fprintf(o.out, "    %-36s  %12s\n", leg.Account, value); err != nil {
+			return fmt.Errorf("error writing ledger posting: %w", err)
+		}
+	}
+	if _, err := fmt.Fprintln(o.out); err != nil {
+		return fmt.Errorf("error writing ledger entry: %w", err)
+	}
+	return nil
+}
+
+// appendResidual appends a posting to the account with the residual of the
+// legs, if their values don't add up to zero, as after rounding splits
+// changed by a -hook.
+func appendResidual(legs []posting, account string) []posting {
+	var sum amount
+	for _, leg := range legs {
+		value, err := parseAmount(leg.Value)
+		if err != nil {
+			return legs
+		}
+		sum = sum.Add(value)
+	}
+	if sum.IsZero() {
+		return legs
+	}
+	return append(legs, posting{Account: account, Value: sum.Neg().String()})
+}
+
+// converted returns the amount of a posting of a transaction converted to the
+// -base-currency, with the currencies so that the entry balances: the source
+// posting has the original amount with the total price annotation, like
+// -12.50 EUR @@ 15.63 USD, and the others the converted amounts. The
+// converted amounts have the code of the base currency instead of the
+// -currency-symbol, which is not necessarily the one of the base.
+func (o *outputLedgerFormat) converted(t *transaction, i int, leg posting) string {
+	if i > 0 {
+		return negate(leg.Value) + " " + t.Currency
+	}
+	return fmt.Sprintf("%s %s @@ %s %s", negate(t.OrigValue), t.OrigCurrency, strings.TrimPrefix(t.Value, "-"), t.Currency)
+}
+
+func (o *outputLedgerFormat) Finish() error {
+	if o.comment && !o.first.IsZero() {
+		if _, err := fmt.Fprintf(o.out, "; Transactions from %s to %s\n", o.first.Format("2006-01-02"), o.last.Format("2006-01-02")); err != nil {
+			return fmt.Errorf("error writing ledger comment: %w", err)
+		}
+	}
+	return o.out.Flush()
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLedgerConvertedPrice(t *testing.T) {
+	opts := options{outputDateFormat: "2006/01/02", symbol: currencySymbol{symbol: "$"}}
+	o := newOutputLedgerFormat(&opts, &config{}, "Assets:Checking")
+	var out bytes.Buffer
+	if err := o.Init(&out); err != nil {
+		t.Fatal(err)
+	}
+	tr := transaction{Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Description: "TESCO",
+		Value: "15.63", Currency: "EUR", OrigValue: "12.50", OrigCurrency: "GBP",
+		SrcAccount: "Assets:Checking", Account: "Expenses:Groceries"}
+	if err := o.Add(&tr); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	// The converted amounts are in the base currency, not in the symbol.
+	want := "2018/03/01 TESCO\n" +
+		"    Assets:Checking                       -12.50 GBP @@ 15.63 EUR\n" +
+		"    Expenses:Groceries                       15.63 EUR\n\n"
+	if out.String() != want {
+		t.Errorf("got\n%q\nwant\n%q", out.String(), want)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// numberFormat has the separators used to write numbers in a locale. The
+// zero value is the neutral format, that leaves numbers like 1234.56
+// untouched.
+type numberFormat struct {
+	decimal  string
+	grouping []string
+}
+
+var (
+	numbersDotComma   = numberFormat{decimal: ".", grouping: []string{","}}
+	numbersCommaDot   = numberFormat{decimal: ",", grouping: []string{"."}}
+	numbersCommaSpace = numberFormat{decimal: ",", grouping: []string{" ", "\u00a0", "\u202f"}}
+	numbersDotQuote   = numberFormat{decimal: ".", grouping: []string{"'", "\u2019"}}
+)
+
+// localeNumbers maps locales to their number format; languages are used as a
+// fallback when the full locale is not listed.
+var localeNumbers = map[string]numberFormat{
+	"en":    numbersDotComma,
+	"ga":    numbersDotComma,
+	"ja":    numbersDotComma,
+	"zh":    numbersDotComma,
+	"de":    numbersCommaDot,
+	"es":    numbersCommaDot,
+	"it":    numbersCommaDot,
+	"nl":    numbersCommaDot,
+	"pt":    numbersCommaDot,
+	"da":    numbersCommaDot,
+	"id":    numbersCommaDot,
+	"tr":    numbersCommaDot,
+	"fr":    numbersCommaSpace,
+	"sv":    numbersCommaSpace,
+	"nb":    numbersCommaSpace,
+	"fi":    numbersCommaSpace,
+	"pl":    numbersCommaSpace,
+	"cs":    numbersCommaSpace,
+	"ru":    numbersCommaSpace,
+	"pt-PT": numbersCommaSpace,
+	"de-CH": numbersDotQuote,
+	"fr-CH": numbersDotQuote,
+	"it-CH": numbersDotQuote,
+}
+
+// localeNumberFormat returns the number format of a locale like "de-DE" or
+// "fr_FR"; the empty locale is the neutral format.
+func localeNumberFormat(locale string) (numberFormat, error) {
+	if locale == "" {
+		return numberFormat{}, nil
+	}
+	tag := strings.Replace(locale, "_", "-", -1)
+	if nf, ok := localeNumbers[tag]; ok {
+		return nf, nil
+	}
+	if nf, ok := localeNumbers[strings.ToLower(strings.Split(tag, "-")[0])]; ok {
+		return nf, nil
+	}
+	return numberFormat{}, fmt.Errorf("unknown locale %q", locale)
+}
+
+// normalize converts a number written in the format to the 1234.56 form.
+func (nf *numberFormat) normalize(value string) string {
+	if nf.decimal == "" {
+		return value
+	}
+	for _, sep := range nf.grouping {
+		value = strings.Replace(value, sep, "", -1)
+	}
+	return strings.Replace(value, nf.decimal, ".", 1)
+}
+
+// group writes a number in the 1234.56 form with the thousands grouped, with
+// the separators of the format, or as 1,234.56 in the neutral format. Values
+// that are not numbers are returned untouched.
+func (nf *numberFormat) group(value string) string {
+	if _, err := parseAmount(value); err != nil {
+		return value
+	}
+	decimal, sep := ".", ","
+	if nf.decimal != "" {
+		decimal, sep = nf.decimal, nf.grouping[0]
+	}
+	sign := ""
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		sign, value = value[:1], value[1:]
+	}
+	intPart, fracPart := value, ""
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		intPart, fracPart = value[:i], decimal+value[i+1:]
+	}
+	var b strings.Builder
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String() + fracPart
+}
+
+// localeMonths has the month names of the languages of -date-locale, in
+// lowercase; their abbreviations are the unambiguous prefixes.
+var localeMonths = map[string][]string{
+	"de": {"januar", "februar", "märz", "april", "mai", "juni", "juli", "august", "september", "oktober", "november", "dezember"},
+	"es": {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+	"fr": {"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	"it": {"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+	"nl": {"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+	"pt": {"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+}
+
+// localeMonthNames returns the month names of the language of a locale like
+// "es-ES"; the empty locale has none, for the english names of time.Parse.
+func localeMonthNames(locale string) ([]string, error) {
+	if locale == "" {
+		return nil, nil
+	}
+	language := strings.ToLower(strings.Split(strings.Replace(locale, "_", "-", -1), "-")[0])
+	if months, ok := localeMonths[language]; ok {
+		return months, nil
+	}
+	return nil, fmt.Errorf("unknown date locale %q", locale)
+}
+
+// englishMonths replaces the localized month names and abbreviations in the
+// date by the english ones, full or abbreviated as in the layout.
+func englishMonths(date string, layout string, months []string) string {
+	full := strings.Contains(layout, "January")
+	var b strings.Builder
+	word := []rune{}
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		text := string(word)
+		word = word[:0]
+		month := monthByName(strings.TrimSuffix(strings.ToLower(text), "."), months)
+		if month == 0 {
+			b.WriteString(text)
+			return
+		}
+		name := time.Month(month).String()
+		if !full {
+			name = name[:3]
+		}
+		if strings.HasSuffix(text, ".") {
+			name += "."
+		}
+		b.WriteString(name)
+	}
+	for _, c := range date {
+		if unicode.IsLetter(c) || c == '.' {
+			word = append(word, c)
+			continue
+		}
+		flush()
+		b.WriteRune(c)
+	}
+	flush()
+	return b.String()
+}
+
+// monthByName returns the month, from 1, whose name is the word or starts
+// with it when it has at least 3 letters and no other month does; 0 is no
+// month.
+func monthByName(word string, months []string) int {
+	if len([]rune(word)) < 3 {
+		return 0
+	}
+	found := 0
+	for i, name := range months {
+		if name == word {
+			return i + 1
+		}
+		if strings.HasPrefix(name, word) {
+			if found != 0 {
+				return 0
+			}
+			found = i + 1
+		}
+	}
+	return found
+}
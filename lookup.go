@@ -0,0 +1,52 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Lookup:
+
+// lookupKey normalizes a description for comparisons: case and repeated
+// whitespace don't matter.
+func lookupKey(description string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(description), " "))
+}
+
+// loadLookup reads a csv file with merchant,account lines into a map by the
+// lookupKey of the merchant. A merchant,account header is skipped.
+func loadLookup(fileName string) (map[string]string, error) {
+	fd, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close() // nolint: errcheck
+	r := csv.NewReader(fd)
+	r.FieldsPerRecord = 2
+	lookup := map[string]string{}
+	for lineNum := 1; ; lineNum++ {
+		line, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+		if lineNum == 1 && strings.EqualFold(line[0], "merchant") && strings.EqualFold(line[1], "account") {
+			continue
+		}
+		key, account := lookupKey(line[0]), strings.TrimSpace(line[1])
+		if prev, ok := lookup[key]; ok && prev != account {
+			warnf("%s:%d: merchant %q repeated with accounts %s and %s", fileName, lineNum, line[0], prev, account)
+		}
+		lookup[key] = account
+	}
+	return lookup, nil
+}
@@ -5,244 +5,2887 @@
 package main
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // Base types:
 
 type transaction struct {
-	ID          string
-	Date        time.Time
-	Description string
-	Value       string
-	Account     string
-	SrcAccount  string
+	ID           string
+	Date         time.Time
+	Description  string
+	Value        string
+	RawValue     string // amount as written in the input, before normalization
+	Balance      string // of the source account after the transaction, if in the input
+	Currency     string // ISO 4217 code, if in the input or given by -currency
+	OrigValue    string // before the -base-currency conversion, if converted
+	OrigCurrency string
+	Account      string
+	SrcAccount   string
+	Type         string // "debit" or "credit"
+	Splits       []posting
+	Payee        string
+	Fields       []string // raw input columns
+	Source       string   // raw input line, with -keep-source-line
+	File         string   // input file name
+	Line         int      // record number in the input file
+	timed        bool     // the date has a time of the day, for -output-tz
+	rowAccount   string   // of the row read back by -informat bankcsv
+	fileID       string   // the id counted in the input only, as with -id-scope file, for -dedup-by id
 }
 
-// json config parsing: ///////////////////////////////////////////////////////
-
-type config struct {
-	AccountFromDescription []accountFromDescription
+// posting is a share of the value of a transaction assigned to an account.
+type posting struct {
+	Account string
+	Value   string
 }
 
-type accountFromDescription struct {
-	Account string
-	Regex   string
+// dstLegs returns the destination legs of the transaction, with their values
+// negated so that they balance the source leg.
+func (t *transaction) dstLegs() []posting {
+	if len(t.Splits) == 0 {
+		if t.Account == "" {
+			return nil
+		}
+		return []posting{{Account: t.Account, Value: negate(t.Value)}}
+	}
+	legs := make([]posting, len(t.Splits))
+	for i, p := range t.Splits {
+		legs[i] = posting{Account: p.Account, Value: negate(p.Value)}
+	}
+	return legs
 }
 
-func configFromJSON(jsonName *string) (config, error) {
-	dat, err := ioutil.ReadFile(*jsonName)
-	var cfg config
-	if err != nil {
-		return cfg, err
+// negate returns the value with the opposite sign; zero is never signed and
+// the empty value stays empty.
+func negate(value string) string {
+	if value == "" {
+		return value
 	}
-	if err := json.Unmarshal(dat, &cfg); err != nil {
-		return cfg, err
+	a, err := parseAmount(value)
+	if err != nil {
+		// Not a plain number, flip the sign textually.
+		switch value[0] {
+		case '-':
+			return value[1:]
+		case '+':
+			value = value[1:]
+		}
+		return "-" + value
 	}
-	return cfg, nil
+	return a.Neg().String()
 }
 
-// output formats: ////////////////////////////////////////////////////////////
+// isZero checks if the value is a number equal to zero, like 0.00 or 0.
+func isZero(value string) bool {
+	a, err := parseAmount(value)
+	return err == nil && a.IsZero()
+}
 
-// CSV:
+// options: //////////////////////////////////////////////////////////////////
 
-type outputCsvFormat struct {
-	outFd  *os.File
-	outCsv *csv.Writer
+type options struct {
+	outputName          string
+	outputDateFormat    string
+	gzip                bool
+	legs                string
+	typeColumn          bool
+	configDir           string
+	configEnv           string
+	locale              string
+	numbers             numberFormat // from locale
+	sinceID             string
+	sinceDate           time.Time
+	stateName           string
+	crlf                bool
+	quote               string
+	keepSourceLine      bool
+	format              string
+	bank                *inputLayout
+	noTrim              bool
+	openingBalance      string
+	openingAccount      string
+	skipBadLines        bool
+	maxErrors           int
+	accountSeparator    string
+	accountPrefix       string
+	srcAccountPrefix    string
+	jsonNumbers         bool
+	coalesce            bool
+	configTimeout       time.Duration
+	statsName           string
+	csvStyle            string
+	normalizeUnicode    bool
+	verifyBalance       bool
+	fields              []string // selected by -fields, nil for the defaults
+	allowEmpty          bool
+	idPrefix            string
+	pretty              bool
+	lookupName          string
+	maxDescLen          int
+	currency            string
+	strict              bool
+	informat            string
+	jsonFields          jsonInputFields // of -informat json
+	stdinFormat         string          // csv or zip, of the "-" input
+	dropZero            bool
+	recordSep           byte
+	reconcile           string   // expected net change of the source accounts
+	tolerance           string   // of -reconcile
+	headerComment       bool     // in the ledger formats
+	inputNames          []string // of the header comment
+	ruleStats           bool
+	only                string // debits or credits
+	utf8BOM             bool
+	emptyAsNull         bool // in the json formats
+	roundingAccount     string
+	ynabAmount          bool // a single amount column in the ynab format
+	idScope             string
+	zeroFallback        string
+	maxAge              time.Duration  // of the input files, 0 for any
+	location            *time.Location // of the input dates
+	outputLocation      *time.Location // of the output dates, nil to keep them
+	continueOnFileError bool
+	failedInputs        []string // skipped by -continue-on-file-error, known after the parsing
+	unmatchedName       string
+	dedupBy             []string // key fields of -dedup, nil without it
+	dedupWindow         time.Duration
+	dedupKeepLast       bool
+	dedupInputs         bool // only the duplicates across inputs, -dedup-scope inputs
+	count               bool // print the number of transactions instead of writing them
+	srcAccountColumn    int
+	overrides           layoutOverrides
+	centuryPivot        int
+	drcr                bool
+	cents               bool
+	sortBy              []sortKey
+	collapseNewlines    bool
+	symbol              currencySymbol
+	groupOutput         bool
+	baseCurrency        string
+	ratesName           string
+	fxAPI               string // url with a {base} placeholder
+	fxTimeout           time.Duration
+	tree                bool
+	diffName            string // existing output compared with the run
+	showDiff            bool
+	skeletonName        string // config with rules for the unmatched descriptions
+	hook                string // shell command that changes each transaction
+	keepRawValue        bool
+	dateLocale          string
+	months              []string // names of the months of -date-locale
+	fileDates           *fileDates
+	noWarnUnmatched     bool
+	flushEvery          int
+	stripNumbers        *regexp.Regexp // of the references removed from the descriptions
+	outDir              string
+	formats             []string // of -out-dir
+	filter              filterExpr
+}
+
+// sourceIDPrefix returns the prefix of the ids of the transactions of the
+// source account set by -id-prefix; "auto" derives it from the last
+// component of the account name, like CHECKING- for Assets:Checking.
+func (opts *options) sourceIDPrefix(srcAccount string) string {
+	if opts.idPrefix != "auto" {
+		return opts.idPrefix
+	}
+	components := strings.Split(srcAccount, opts.accountSeparator)
+	var code strings.Builder
+	for _, c := range strings.ToUpper(components[len(components)-1]) {
+		if code.Len() < 8 && (c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			code.WriteRune(c)
+		}
+	}
+	return code.String() + "-"
 }
 
-func (o *outputCsvFormat) Init(outFd *os.File) {
-	o.outFd = outFd
-	_, err := outFd.WriteString("\"id\",\"date\",\"description\",\"withdrawal\",\"account\"\n")
+// checkCurrency normalizes the currency of the transaction, defaulting to
+// -currency. Invalid currencies are an error with -strict, and a warning
+// otherwise.
+func (opts *options) checkCurrency(t *transaction) error {
+	if t.Currency == "" {
+		t.Currency = opts.currency
+		return nil
+	}
+	currency, err := normalizeCurrency(t.Currency)
 	if err != nil {
-		log.Fatalln("error writing csv header:", err)
+		if opts.strict {
+			return fmt.Errorf("%s:%d: %w", t.File, t.Line, err)
+		}
+		warnTransactionf(t, "%s", err)
 	}
-	o.outCsv = csv.NewWriter(outFd)
+	t.Currency = currency
+	return nil
 }
 
-func (o *outputCsvFormat) Add(t *transaction) {
-	date := t.Date.Format("2006-01-02")
-	src := []string{t.ID, date, t.Description, t.Value, t.SrcAccount}
-	if err := o.outCsv.Write(src); err != nil {
-		log.Fatalln("error writing src record to csv:", err)
+// writesFormat checks if the output has the format, as -f or one of the
+// formats of -out-dir.
+func (opts *options) writesFormat(format string) bool {
+	if opts.format == format {
+		return true
 	}
-	if t.Account != "" {
-		value := ""
-		if t.Value[0] == '-' {
-			value = t.Value[1:]
-		} else {
-			value = fmt.Sprintf("-%s", t.Value)
+	for _, f := range opts.formats {
+		if f == format {
+			return true
 		}
-		dst := []string{"", "", "", value, t.Account}
-		if err := o.outCsv.Write(dst); err != nil {
-			log.Fatalln("error writing dst record to csv:", err)
+	}
+	return false
+}
+
+// dateLocation returns the location of -tz, of the parsed dates and of the
+// dates they are compared with, like the periods of -date-from-filename and
+// the bounds of -since-date, of -filter and of the rules.
+func (opts *options) dateLocation() *time.Location {
+	if opts.location == nil {
+		return time.UTC
+	}
+	return opts.location
+}
+
+// prefixAccount prepends the prefix to a non-empty account.
+func (opts *options) prefixAccount(prefix string, account string) string {
+	if prefix == "" || account == "" {
+		return account
+	}
+	return prefix + opts.accountSeparator + account
+}
+
+// json config parsing: ///////////////////////////////////////////////////////
+
+type config struct {
+	Include                []string          // configs merged before this one
+	SrcAccount             string            // used when not given in the arguments
+	Output                 map[string]string // defaults of the flags, by name
+	AccountFromDescription []accountFromDescription
+	Merchants              []merchant
+	Accounts               []string // valid accounts, if not empty
+	AccountTypes           map[string]string
+	OpeningBalances        map[string]string // by source account, for -verify-balance
+	Aliases                map[string]string // output names of the assigned accounts
+	SrcAccounts            map[string]string // by the account ids of -srcaccount-col and -informat json
+	AccountOrder           []string          // of -sort-by account, before the unlisted accounts
+	DefaultAccount         string            // of the unmatched transactions without one of their sign
+	DefaultDebitAccount    string            // of the unmatched debits
+	DefaultCreditAccount   string            // of the unmatched credits
+	InputFormat            *inputLayout      // of the inputs, when -bank isn't given
+	separator              string            // of account names, from -account-separator
+	lookup                 map[string]string // accounts by lookupKey of the description, from -lookup
+	ruleHits               []int             // transactions assigned by each rule, for -rule-stats
+}
+
+// accountTypeSigns has the expected sign of the withdrawal value of the
+// postings to each type of account, 0 meaning any.
+var accountTypeSigns = map[string]int{
+	"asset":     0,
+	"liability": 0,
+	"equity":    0,
+	"expense":   -1,
+	"income":    1,
+}
+
+// accountType returns the type of the account, inherited from the closest
+// parent account that has one.
+func (cfg *config) accountType(account string) string {
+	for account != "" {
+		if typ, ok := cfg.AccountTypes[account]; ok {
+			return typ
 		}
+		i := strings.LastIndex(account, cfg.separator)
+		if i < 0 {
+			break
+		}
+		account = account[:i]
 	}
+	return ""
 }
 
-func (o *outputCsvFormat) Finish() {
-	o.outCsv.Flush()
-	if err := o.outCsv.Error(); err != nil {
-		log.Fatal(err)
+// defaultAccount returns the default account of an unmatched transaction,
+// by its sign, falling back to DefaultAccount, or "" if there's none.
+func (cfg *config) defaultAccount(t *transaction) string {
+	account := ""
+	switch valueSign(t) {
+	case "debit":
+		account = cfg.DefaultDebitAccount
+	case "credit":
+		account = cfg.DefaultCreditAccount
+	}
+	if account == "" {
+		account = cfg.DefaultAccount
 	}
+	return account
 }
 
-// parser: ////////////////////////////////////////////////////////////////////
+// alias returns the output name of an assigned account, which is the
+// account itself when it has no alias.
+func (cfg *config) alias(account string) string {
+	if name, ok := cfg.Aliases[account]; ok {
+		return name
+	}
+	return account
+}
 
-func ymdParse(line string, lastdate *time.Time, counter *int) (time.Time, int, time.Month, int) {
-	date, err := time.Parse("02/01/2006", line)
-	if err != nil {
-		log.Fatal(err)
+// checkSigns warns about the postings of t whose sign contradicts the type
+// of their account.
+func (cfg *config) checkSigns(t *transaction) {
+	legs := append([]posting{{Account: t.SrcAccount, Value: t.Value}}, t.dstLegs()...)
+	for _, leg := range legs {
+		typ := cfg.accountType(leg.Account)
+		expected := accountTypeSigns[typ]
+		if expected == 0 {
+			continue
+		}
+		value, err := parseAmount(leg.Value)
+		if err != nil {
+			continue
+		}
+		if value.Sign() == -expected {
+			warnTransactionf(t, "%s %s: posting of %s to %s account %s has the wrong sign",
+				t.ID, t.Description, leg.Value, typ, leg.Account)
+		}
 	}
-	if date != *lastdate {
-		*counter = 1
-		*lastdate = date
+}
+
+// merchant sets the payee of the transactions whose description matches
+// Regex; the first matching merchant wins.
+type merchant struct {
+	Payee string
+	Regex string
+	re    *regexp.Regexp
+}
+
+func (cfg *config) payee(description string) string {
+	for _, m := range cfg.Merchants {
+		if m.re.MatchString(description) {
+			return m.Payee
+		}
 	}
-	y, m, d := date.Date()
-	return date, y, m, d
+	return ""
 }
 
-func valueParse(line []string, iscredit bool) (value string) {
-	if iscredit {
-		value = strings.TrimSpace(line[3])
-	} else {
-		value = strings.TrimSpace(line[5])
+type accountFromDescription struct {
+	Account   string
+	Regex     string
+	Regexes   []string // alternatives to Regex, any of them matches
+	Sign      string   // "debit" or "credit" to only match those transactions
+	Priority  int      // rules with higher priorities take precedence
+	Splits    []split
+	SearchAll bool   // match against all the input columns, joined by commas
+	After     string // yyyy-mm-dd, only match transactions on or after it
+	Before    string // yyyy-mm-dd, only match transactions before it
+	file      string
+	res       []*regexp.Regexp // compiled regexes()
+	after     time.Time
+	before    time.Time
+}
+
+// regexes returns all the regexes of the rule.
+func (descAcc *accountFromDescription) regexes() []string {
+	if descAcc.Regex == "" {
+		return descAcc.Regexes
 	}
-	if value == "0.00" || value == "" {
-		if iscredit {
-			value = "-" + strings.TrimSpace(line[4])
-		} else {
-			value = "-" + strings.TrimSpace(line[6])
+	return append([]string{descAcc.Regex}, descAcc.Regexes...)
+}
+
+// compile compiles the regexes of the rule, so that the invalid ones are
+// errors when the config is loaded.
+func (descAcc *accountFromDescription) compile() error {
+	descAcc.res = nil
+	for _, regex := range descAcc.regexes() {
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			return fmt.Errorf("rule %q (%s) has invalid regex: %w", descAcc.name(), descAcc.file, err)
 		}
+		descAcc.res = append(descAcc.res, re)
 	}
-	return value
+	return nil
 }
 
-func lineParse(line []string, iscredit bool, lastdate *time.Time, counter *int) transaction {
-	date, year, month, day := ymdParse(line[1], lastdate, counter)
-	value := valueParse(line, iscredit)
-	return transaction{
-		ID:          fmt.Sprintf("%04d%02d%02d%02d", year, month, day, *counter),
-		Date:        date,
-		Description: line[2],
-		Value:       value,
+// name identifies the rule in messages.
+func (descAcc *accountFromDescription) name() string {
+	name := strings.Join(descAcc.regexes(), "|")
+	if descAcc.Sign != "" && name != "" {
+		name += " "
 	}
+	return name + descAcc.Sign
 }
 
-func inputsParse(inputNames []string) <-chan *transaction {
-	out := make(chan *transaction)
-	go func() {
-		defer close(out)
-		lastdate := time.Now()
-		counter := 1
-		for _, inputName := range inputNames {
-			inputFd, err := os.Open(filepath.Clean(inputName))
+// split assigns a part of the value of a transaction to an account. Amount
+// is either a percentage like "50%", a fixed value like "12.00" or empty for
+// the remainder.
+type split struct {
+	Account string
+	Amount  string
+}
+
+// validateSplits checks that the splits of a rule always add up to the value
+// of the transaction: they must have a remainder leg or be percentages that
+// add up to 100%.
+func validateSplits(splits []split) error {
+	remainders := 0
+	total := amount{}
+	allPercent := true
+	for _, sp := range splits {
+		if sp.Account == "" {
+			return fmt.Errorf("split without account")
+		}
+		switch {
+		case sp.Amount == "":
+			remainders++
+		case strings.HasSuffix(sp.Amount, "%"):
+			pct, err := parseAmount(strings.TrimSuffix(sp.Amount, "%"))
 			if err != nil {
-				log.Fatal(err)
-			}
-			inputBuf := bufio.NewReader(inputFd)
-			inputCsv := csv.NewReader(inputBuf)
-			var iscredit bool
-			for {
-				line, err := inputCsv.Read()
-				if err == io.EOF {
-					break
-				} else if err != nil {
-					log.Fatal(err)
-				}
-				if line[1] == " Posted Transactions Date" {
-					switch line[0] {
-					case "Masked Card Number":
-						iscredit = true
-					case "Posted Account":
-						iscredit = false // "debit"
-					default:
-						log.Panicf("unknown input format for %s", inputName)
-					}
-					continue
-				}
-				t := lineParse(line, iscredit, &lastdate, &counter)
-				out <- &t
-				counter++
+				return err
+			}
+			total = total.Add(pct)
+		default:
+			if _, err := parseAmount(sp.Amount); err != nil {
+				return err
 			}
+			allPercent = false
 		}
-	}()
-	return out
+	}
+	if remainders > 1 {
+		return fmt.Errorf("more than one remainder split")
+	}
+	if remainders == 0 && (!allPercent || total.Sub(amount{units: 100}).Sign() != 0) {
+		return fmt.Errorf("splits without a remainder must be percentages that add up to 100%%")
+	}
+	if total.Sub(amount{units: 100}).Sign() > 0 {
+		return fmt.Errorf("split percentages add up to more than 100%%")
+	}
+	return nil
 }
 
-// processor //////////////////////////////////////////////////////////////////
+// splitValue distributes value among the splits. Rounding residuals of
+// percentages are assigned to the remainder, or to the last split if there
+// is none.
+func splitValue(value string, splits []split) ([]posting, error) {
+	total, err := parseAmount(value)
+	if err != nil {
+		return nil, err
+	}
+	postings := make([]posting, len(splits))
+	parts := make([]amount, len(splits))
+	rest := total
+	restIdx := len(splits) - 1
+	for i, sp := range splits {
+		switch {
+		case sp.Amount == "":
+			restIdx = i
+			continue
+		case strings.HasSuffix(sp.Amount, "%"):
+			pct, err := parseAmount(strings.TrimSuffix(sp.Amount, "%"))
+			if err != nil {
+				return nil, err
+			}
+			parts[i] = total.percent(pct)
+		default:
+			part, err := parseAmount(sp.Amount)
+			if err != nil {
+				return nil, err
+			}
+			if total.Sign() < 0 {
+				part = part.Neg()
+			}
+			parts[i] = part.rescale(total.scale)
+		}
+		rest = rest.Sub(parts[i])
+	}
+	parts[restIdx] = parts[restIdx].Add(rest)
+	for i, sp := range splits {
+		postings[i] = posting{Account: sp.Account, Value: parts[i].String()}
+	}
+	return postings, nil
+}
+
+// configMerge parses the json in dat into cfg, appending its rules to the
+// ones already present.
+func configMerge(cfg *config, dat []byte, fileName string) error {
+	return configMergeIncludes(cfg, dat, fileName, nil)
+}
+
+// configMergeIncludes merges the configs included by the one in dat before
+// it, so that its settings take precedence, but puts its rules before
+// theirs, so that they are tried first. The includes are relative to the
+// directory of the including file, and including has the files being
+// merged, to detect cycles.
+func configMergeIncludes(cfg *config, dat []byte, fileName string, including []string) error {
+	var head struct {
+		Include []string
+	}
+	if err := json.Unmarshal(dat, &head); err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+	if len(head.Include) > 0 {
+		self := fileName
+		if !isURL(fileName) && !strings.HasPrefix(fileName, "$") {
+			if abs, err := filepath.Abs(fileName); err == nil {
+				self = abs
+			}
+		}
+		including = append(including, self)
+	}
+	// The rules of the configs merged before this one, in a config dir.
+	previous := len(cfg.AccountFromDescription)
+	for _, include := range head.Include {
+		includeName := include
+		if !filepath.IsAbs(includeName) && !isURL(fileName) && !strings.HasPrefix(fileName, "$") {
+			includeName = filepath.Join(filepath.Dir(fileName), includeName)
+		}
+		abs, err := filepath.Abs(includeName)
+		if err != nil {
+			return err
+		}
+		for _, name := range including {
+			if name == abs {
+				return fmt.Errorf("%s: include cycle: %s", fileName, strings.Join(append(including, abs), " -> "))
+			}
+		}
+		incDat, err := ioutil.ReadFile(filepath.Clean(includeName))
+		if err != nil {
+			return fmt.Errorf("%s: %w", fileName, err)
+		}
+		if err := configMergeIncludes(cfg, incDat, includeName, including); err != nil {
+			return err
+		}
+	}
+	rules := cfg.AccountFromDescription
+	merchants := cfg.Merchants
+	accounts := cfg.Accounts
+	order := cfg.AccountOrder
+	cfg.AccountFromDescription = nil
+	cfg.Merchants = nil
+	cfg.Accounts = nil
+	cfg.AccountOrder = nil
+	if err := json.Unmarshal(dat, cfg); err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
+	}
+	for i := range cfg.AccountFromDescription {
+		cfg.AccountFromDescription[i].file = fileName
+	}
+	merged := append([]accountFromDescription{}, rules[:previous]...)
+	merged = append(merged, cfg.AccountFromDescription...)
+	cfg.AccountFromDescription = append(merged, rules[previous:]...)
+	cfg.Merchants = append(merchants, cfg.Merchants...)
+	cfg.Accounts = append(accounts, cfg.Accounts...)
+	cfg.AccountOrder = append(order, cfg.AccountOrder...)
+	return nil
+}
 
-func processCsvs(srcAccount *string, jsonName *string, outputName *string, inputNames []string) {
-	cfg, err := configFromJSON(jsonName)
+// configFetch gets a config from an http(s) url.
+func configFetch(url string, timeout time.Duration) ([]byte, error) {
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	var outFd *os.File
-	if *outputName == "-" {
-		outFd = os.Stdout
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// isURL checks if the config name is an http(s) url.
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+func configFromJSON(jsonName *string, timeout time.Duration) (config, error) {
+	var dat []byte
+	var err error
+	if isURL(*jsonName) {
+		dat, err = configFetch(*jsonName, timeout)
 	} else {
-		var err error
-		outFd, err = os.Create(*outputName)
+		dat, err = ioutil.ReadFile(*jsonName)
+	}
+	var cfg config
+	if err != nil {
+		return cfg, err
+	}
+	if err := configMerge(&cfg, dat, *jsonName); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// configFromDir merges all .json files in dirName, in name order.
+func configFromDir(dirName string) (config, error) {
+	var cfg config
+	infos, err := ioutil.ReadDir(dirName)
+	if err != nil {
+		return cfg, err
+	}
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".json" {
+			continue
+		}
+		fileName := filepath.Join(dirName, info.Name())
+		dat, err := ioutil.ReadFile(filepath.Clean(fileName))
 		if err != nil {
-			log.Fatal("Error creating file", err)
+			return cfg, err
+		}
+		if err := configMerge(&cfg, dat, fileName); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// configFromEnv parses the config in the environment variable.
+func configFromEnv(name string) (config, error) {
+	var cfg config
+	dat, ok := os.LookupEnv(name)
+	if !ok {
+		return cfg, fmt.Errorf("environment variable %s of -config-env not set", name)
+	}
+	if err := configMerge(&cfg, []byte(dat), "$"+name); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func loadConfig(jsonName *string, opts *options) (config, error) {
+	var cfg config
+	var err error
+	if opts.configEnv != "" {
+		cfg, err = configFromEnv(opts.configEnv)
+	} else if opts.configDir != "" {
+		cfg, err = configFromDir(opts.configDir)
+	} else {
+		cfg, err = configFromJSON(jsonName, opts.configTimeout)
+	}
+	if err != nil {
+		return cfg, err
+	}
+	cfg.separator = opts.accountSeparator
+	if cfg.InputFormat != nil {
+		if err := cfg.InputFormat.validate(); err != nil {
+			return cfg, fmt.Errorf("invalid InputFormat: %w", err)
+		}
+	}
+	for i, descAcc := range cfg.AccountFromDescription {
+		if len(descAcc.regexes()) == 0 && descAcc.Sign == "" {
+			return cfg, fmt.Errorf("rule %d (%s) has no Regex, Regexes or Sign", i+1, descAcc.file)
 		}
-		defer func() {
-			err := outFd.Close()
+		if descAcc.Sign != "" && descAcc.Sign != "debit" && descAcc.Sign != "credit" {
+			return cfg, fmt.Errorf("rule %q (%s) has invalid Sign, it must be debit or credit", descAcc.name(), descAcc.file)
+		}
+		if err := cfg.AccountFromDescription[i].compile(); err != nil {
+			return cfg, err
+		}
+		if descAcc.After != "" {
+			cfg.AccountFromDescription[i].after, err = time.ParseInLocation("2006-01-02", descAcc.After, opts.dateLocation())
 			if err != nil {
-				log.Panicf("error closing %s: %s", *outputName, err)
+				return cfg, fmt.Errorf("rule %q (%s) has invalid After: %w", descAcc.name(), descAcc.file, err)
 			}
-		}()
-	}
-	o := outputCsvFormat{}
-	o.Init(outFd)
-	for t := range inputsParse(inputNames) {
-		t.SrcAccount = *srcAccount
-		found := false
-		for _, descAcc := range cfg.AccountFromDescription {
-			match, err := regexp.MatchString(descAcc.Regex, t.Description)
+		}
+		if descAcc.Before != "" {
+			cfg.AccountFromDescription[i].before, err = time.ParseInLocation("2006-01-02", descAcc.Before, opts.dateLocation())
 			if err != nil {
-				log.Panicf("error in MatchString: %s", err)
+				return cfg, fmt.Errorf("rule %q (%s) has invalid Before: %w", descAcc.name(), descAcc.file, err)
 			}
-			if match {
-				t.Account = descAcc.Account
-				found = true
+		}
+		if len(descAcc.Splits) == 0 {
+			continue
+		}
+		if err := validateSplits(descAcc.Splits); err != nil {
+			return cfg, fmt.Errorf("rule %q: %w", descAcc.name(), err)
+		}
+	}
+	for i := range cfg.Merchants {
+		cfg.Merchants[i].re, err = regexp.Compile(cfg.Merchants[i].Regex)
+		if err != nil {
+			return cfg, fmt.Errorf("merchant %q: %w", cfg.Merchants[i].Payee, err)
+		}
+	}
+	if opts.lookupName != "" {
+		cfg.lookup, err = loadLookup(opts.lookupName)
+		if err != nil {
+			return cfg, err
+		}
+	}
+	if err := checkAccounts(&cfg); err != nil {
+		return cfg, err
+	}
+	if opts.writesFormat("beancount") {
+		if err := checkBeancountAccounts(&cfg, opts); err != nil {
+			return cfg, err
+		}
+	}
+	for account, balance := range cfg.OpeningBalances {
+		if _, err := parseAmount(balance); err != nil {
+			return cfg, fmt.Errorf("opening balance of %s: %w", account, err)
+		}
+	}
+	for account, typ := range cfg.AccountTypes {
+		if _, ok := accountTypeSigns[typ]; !ok {
+			return cfg, fmt.Errorf("account %s has invalid type %q", account, typ)
+		}
+	}
+	// The first matching rule wins, so the higher priorities go first; the
+	// stable sort keeps the file order among rules of the same priority.
+	sort.SliceStable(cfg.AccountFromDescription, func(i, j int) bool {
+		return cfg.AccountFromDescription[i].Priority > cfg.AccountFromDescription[j].Priority
+	})
+	warnDuplicateRules(&cfg)
+	return cfg, nil
+}
+
+// checkAccounts verifies that the rules only assign accounts in the
+// Accounts list, when there is one.
+func checkAccounts(cfg *config) error {
+	if len(cfg.Accounts) == 0 {
+		return nil
+	}
+	valid := map[string]bool{}
+	for _, account := range cfg.Accounts {
+		valid[account] = true
+	}
+	for _, descAcc := range cfg.AccountFromDescription {
+		accounts := []string{descAcc.Account}
+		for _, sp := range descAcc.Splits {
+			accounts = append(accounts, sp.Account)
+		}
+		for _, account := range accounts {
+			if account != "" && !valid[account] {
+				return fmt.Errorf("rule %q (%s) assigns unknown account %q", descAcc.name(), descAcc.file, account)
 			}
 		}
-		if found {
-			o.Add(t)
-		} else {
-			log.Printf("could not assign account to %s", t.Description)
+	}
+	for _, account := range []string{cfg.DefaultAccount, cfg.DefaultDebitAccount, cfg.DefaultCreditAccount} {
+		if account != "" && !valid[account] {
+			return fmt.Errorf("default account %q is unknown", account)
 		}
 	}
-	o.Finish()
+	for merchant, account := range cfg.lookup {
+		if !valid[account] {
+			return fmt.Errorf("lookup of %q assigns unknown account %q", merchant, account)
+		}
+	}
+	return nil
 }
 
-func main() {
-	outputName := flag.String("o", "-", "output file")
-	flag.Parse()
-	numArgs := 3
-	if flag.NArg() != numArgs {
-		fmt.Fprintf(os.Stderr, "Wrong number of arguments\n")                                  // nolint: errcheck
-		fmt.Fprintf(os.Stderr, "Usage: bankcsv <srcAccount> <json config file> <inputs...>\n") // nolint: errcheck
-		flag.PrintDefaults()
-		exitError := 1
-		os.Exit(exitError)
+// warnDuplicateRules logs the regexes that appear in more than one rule,
+// which is usually a mistake when rules are split across files.
+func warnDuplicateRules(cfg *config) {
+	seen := map[string]accountFromDescription{}
+	for _, descAcc := range cfg.AccountFromDescription {
+		for _, regex := range descAcc.regexes() {
+			// Rules with different conditions repeat regexes on purpose.
+			key := strings.Join([]string{regex, descAcc.Sign, descAcc.After, descAcc.Before}, "\x00")
+			if prev, ok := seen[key]; ok {
+				warnf("regex %q repeated: %s (%s) and %s (%s)",
+					regex, prev.Account, prev.file, descAcc.Account, descAcc.file)
+				continue
+			}
+			seen[key] = descAcc
+		}
+	}
+}
+
+// account assignment: ///////////////////////////////////////////////////////
+
+// matches returns the indexes of the rules that match t, in order.
+func (cfg *config) matches(t *transaction) []int {
+	var idxs []int
+	for i := range cfg.AccountFromDescription {
+		if cfg.AccountFromDescription[i].match(t) {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs
+}
+
+// winner returns the index of the rule that assigns t, the first matching
+// one, or -1 if none matches.
+func (cfg *config) winner(t *transaction) int {
+	for i := range cfg.AccountFromDescription {
+		if cfg.AccountFromDescription[i].match(t) {
+			return i
+		}
+	}
+	return -1
+}
+
+// valueSign returns "debit" or "credit" according to the sign of the value of
+// the transaction, or "" if it's zero or not a number.
+func valueSign(t *transaction) string {
+	value, err := parseAmount(t.Value)
+	if err != nil || value.IsZero() {
+		return ""
+	}
+	if value.Sign() > 0 {
+		return "debit"
+	}
+	return "credit"
+}
+
+// match checks if the rule matches the transaction.
+func (descAcc *accountFromDescription) match(t *transaction) bool {
+	if descAcc.Sign != "" && valueSign(t) != descAcc.Sign {
+		return false
+	}
+	// Transactions without a date, as in -explain, match all windows.
+	if !t.Date.IsZero() {
+		if !descAcc.after.IsZero() && t.Date.Before(descAcc.after) {
+			return false
+		}
+		if !descAcc.before.IsZero() && !t.Date.Before(descAcc.before) {
+			return false
+		}
+	}
+	if len(descAcc.res) == 0 {
+		return true
+	}
+	text := t.Description
+	if descAcc.SearchAll {
+		text = strings.Join(t.Fields, ",")
+	}
+	for _, re := range descAcc.res {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// assign sets the account of t from the winning rule, returning false if no
+// rule matches.
+func (cfg *config) assign(t *transaction) (bool, error) {
+	if account, ok := cfg.lookup[lookupKey(t.Description)]; ok {
+		t.Account = account
+		t.Splits = nil
+		return true, nil
+	}
+	i := cfg.winner(t)
+	if i < 0 {
+		return false, nil
+	}
+	descAcc := cfg.AccountFromDescription[i]
+	if cfg.ruleHits != nil {
+		cfg.ruleHits[i]++
+	}
+	t.Account = descAcc.Account
+	t.Splits = nil
+	if len(descAcc.Splits) > 0 {
+		var err error
+		t.Splits, err = splitValue(t.Value, descAcc.Splits)
+		if err != nil {
+			return false, fmt.Errorf("error splitting %s: %w", t.Description, err)
+		}
+	}
+	return true, nil
+}
+
+// explain prints the rules that match the description and the account
+// assigned by them.
+func explain(out io.Writer, cfg *config, description string) error {
+	if account, ok := cfg.lookup[lookupKey(description)]; ok {
+		_, err := fmt.Fprintf(out, "assigned by the lookup: %s\n", account)
+		return err
+	}
+	t := transaction{Description: description, Fields: []string{description}}
+	idxs := cfg.matches(&t)
+	for _, i := range idxs {
+		descAcc := cfg.AccountFromDescription[i]
+		target := descAcc.Account
+		for _, sp := range descAcc.Splits {
+			target += fmt.Sprintf(" %s=%s", sp.Account, sp.Amount)
+		}
+		fmt.Fprintf(out, "rule %d %q (%s) matches: %s\n", i+1, descAcc.name(), descAcc.file, target) // nolint: errcheck
+	}
+	if len(idxs) == 0 {
+		_, err := fmt.Fprintln(out, "unmatched")
+		return err
+	}
+	_, err := fmt.Fprintf(out, "assigned by rule %d, the first one that matches\n", idxs[0]+1)
+	return err
+}
+
+// output formats: ////////////////////////////////////////////////////////////
+
+// outputFormat writes the transactions in a format. The formats are not safe
+// for concurrent use: processCsvs is the single consumer of the parsing
+// pipeline and the only caller of Add, in the order of the inputs. The
+// errors are returned to processCsvs, which stops at the first one.
+type outputFormat interface {
+	Init(out io.Writer) error
+	Add(t *transaction) error
+	Finish() error
+}
+
+// outputFormats has the constructors of the output formats by name.
+var outputFormats = map[string]func(opts *options, cfg *config, srcAccount string) outputFormat{
+	"csv":       newOutputCsvFormat,
+	"ledger":    newOutputLedgerFormat,
+	"hledger":   newOutputLedgerFormat,
+	"json":      newOutputJSONFormat,
+	"jsonl":     newOutputJSONLinesFormat,
+	"table":     newOutputTableFormat,
+	"ynab":      newOutputYNABFormat,
+	"beancount": newOutputBeancountFormat,
+}
+
+// outputExtensions maps the extensions of output files to the name of their
+// formats, used by the auto format.
+var outputExtensions = map[string]string{
+	".csv":       "csv",
+	".ledger":    "ledger",
+	".journal":   "hledger",
+	".json":      "json",
+	".jsonl":     "jsonl",
+	".beancount": "beancount",
+}
+
+// outputFields are the fields that -fields can select.
+var outputFields = []string{"id", "date", "description", "value", "account", "srcaccount", "type", "payee", "balance", "currency", "source", "raw_value"}
+
+// parseFields parses the comma-separated list of -fields.
+func parseFields(list string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		known := false
+		for _, f := range outputFields {
+			known = known || f == field
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field %q, must be one of %s", field, strings.Join(outputFields, ","))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// transactionField returns the value of an output field of the transaction.
+func transactionField(t *transaction, field string, dateFormat string) string {
+	switch field {
+	case "id":
+		return t.ID
+	case "date":
+		return t.Date.Format(dateFormat)
+	case "description":
+		return t.Description
+	case "value":
+		return t.Value
+	case "account":
+		return t.Account
+	case "srcaccount":
+		return t.SrcAccount
+	case "type":
+		return t.Type
+	case "payee":
+		return t.Payee
+	case "balance":
+		return t.Balance
+	case "currency":
+		return t.Currency
+	case "source":
+		return t.Source
+	case "raw_value":
+		return t.RawValue
+	}
+	return ""
+}
+
+// outputFormatNames returns the sorted names of the output formats, for the
+// errors.
+func outputFormatNames() string {
+	var names []string
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// outputFormatName resolves the name of the output format, inferring it from
+// the output file name when it's "auto".
+func outputFormatName(format string, outputName string) (string, error) {
+	if format != "auto" {
+		if _, ok := outputFormats[format]; !ok {
+			return "", fmt.Errorf("unknown output format %q, must be one of %s", format, outputFormatNames())
+		}
+		return format, nil
+	}
+	ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(outputName, ".gz")))
+	if name, ok := outputExtensions[ext]; ok {
+		return name, nil
+	}
+	return "csv", nil
+}
+
+// CSV:
+
+// recordWriter is implemented by csv.Writer and quoteAllWriter.
+type recordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+// quoteAllWriter writes csv records quoting every field.
+type quoteAllWriter struct {
+	w   *bufio.Writer
+	eol string
+	err error
+}
+
+func newQuoteAllWriter(out io.Writer, crlf bool) *quoteAllWriter {
+	q := quoteAllWriter{w: bufio.NewWriter(out), eol: "\n"}
+	if crlf {
+		q.eol = "\r\n"
+	}
+	return &q
+}
+
+func (q *quoteAllWriter) Write(record []string) error {
+	for i, field := range record {
+		if i > 0 {
+			if err := q.w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		quoted := "\"" + strings.Replace(field, "\"", "\"\"", -1) + "\""
+		if _, err := q.w.WriteString(quoted); err != nil {
+			return err
+		}
+	}
+	_, err := q.w.WriteString(q.eol)
+	return err
+}
+
+func (q *quoteAllWriter) Flush() {
+	q.err = q.w.Flush()
+}
+
+func (q *quoteAllWriter) Error() error {
+	return q.err
+}
+
+type outputCsvFormat struct {
+	out        io.Writer
+	outCsv     recordWriter
+	dateFormat string
+	legs       string // "both", "src" or "dst"
+	valueLabel string
+	fields     []string
+	symbol     currencySymbol // of the values, only in the table format
+	group      *numberFormat  // separators of the values, only in the table format
+	crlf       bool
+	quoteAll   bool
+	bom        bool // UTF-8 byte order mark before the header
+}
+
+func newOutputCsvFormat(opts *options, cfg *config, srcAccount string) outputFormat {
+	legs, valueLabel := opts.legs, "withdrawal"
+	if opts.csvStyle == "signed" {
+		// The destination legs have the signed amounts of the source
+		// account, one row each.
+		legs, valueLabel = "dst", "amount"
+	}
+	fields := opts.fields
+	if fields == nil {
+		fields = []string{"id", "date", "description", "value", "account"}
+		if opts.typeColumn {
+			fields = append(fields, "type")
+		}
+		if len(cfg.Merchants) > 0 {
+			fields = append(fields, "payee")
+		}
+		if opts.keepSourceLine {
+			fields = append(fields, "source")
+		}
+		if opts.keepRawValue {
+			fields = append(fields, "raw_value")
+		}
+	}
+	return &outputCsvFormat{
+		dateFormat: opts.outputDateFormat,
+		legs:       legs,
+		valueLabel: valueLabel,
+		fields:     fields,
+		crlf:       opts.crlf,
+		quoteAll:   opts.quote == "all",
+		bom:        opts.utf8BOM,
+	}
+}
+
+func (o *outputCsvFormat) Init(out io.Writer) error {
+	o.out = out
+	if o.quoteAll {
+		o.outCsv = newQuoteAllWriter(out, o.crlf)
+	} else {
+		w := csv.NewWriter(out)
+		w.UseCRLF = o.crlf
+		o.outCsv = w
+	}
+	return o.writeHeader()
+}
+
+// writeHeader writes the names of the columns, after the byte order mark
+// of -utf8-bom.
+func (o *outputCsvFormat) writeHeader() error {
+	if o.bom {
+		if _, err := io.WriteString(o.out, "\ufeff"); err != nil {
+			return fmt.Errorf("error writing csv header: %w", err)
+		}
+	}
+	header := make([]string, len(o.fields))
+	for i, field := range o.fields {
+		header[i] = field
+		if field == "value" {
+			header[i] = o.valueLabel
+		}
+	}
+	if err := o.outCsv.Write(header); err != nil {
+		return fmt.Errorf("error writing csv header: %w", err)
+	}
+	return nil
+}
+
+// record returns the columns of a leg of the transaction; the fields that
+// are not of the leg are only filled in when full.
+func (o *outputCsvFormat) record(t *transaction, leg posting, full bool) []string {
+	record := make([]string, len(o.fields))
+	for i, field := range o.fields {
+		switch {
+		case field == "value":
+			record[i] = o.display(leg.Value)
+		case field == "balance" && full:
+			record[i] = o.display(t.Balance)
+		case field == "account":
+			record[i] = leg.Account
+		case full:
+			record[i] = transactionField(t, field, o.dateFormat)
+		}
+	}
+	return record
+}
+
+// display returns the value with the thousands grouped and the currency
+// symbol, when they are set.
+func (o *outputCsvFormat) display(value string) string {
+	if o.group != nil {
+		value = o.group.group(value)
+	}
+	return o.symbol.format(value)
+}
+
+func (o *outputCsvFormat) Add(t *transaction) error {
+	if o.legs != "dst" {
+		src := o.record(t, posting{Account: t.SrcAccount, Value: t.Value}, true)
+		if err := o.outCsv.Write(src); err != nil {
+			return fmt.Errorf("error writing src record to csv: %w", err)
+		}
+	}
+	if o.legs == "src" {
+		return nil
+	}
+	for _, leg := range t.dstLegs() {
+		dst := o.record(t, leg, o.legs == "dst")
+		if err := o.outCsv.Write(dst); err != nil {
+			return fmt.Errorf("error writing dst record to csv: %w", err)
+		}
+	}
+	return nil
+}
+
+func (o *outputCsvFormat) Finish() error {
+	return o.flush()
+}
+
+// csvJoin encodes fields back into a csv line.
+func csvJoin(fields []string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	if err := w.Write(fields); err != nil {
+		return strings.Join(fields, ",")
+	}
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// parser: ////////////////////////////////////////////////////////////////////
+
+// ymdParse parses the date of a line in the location of -tz. Two-digit
+// years below the pivot are in the 2000s, and the others in the 1900s.
+func ymdParse(line string, layout string, loc *time.Location, pivot int) (time.Time, error) {
+	date, err := time.ParseInLocation(layout, line, loc)
+	if err != nil {
+		return date, err
+	}
+	if strings.Contains(strings.Replace(layout, "2006", "", -1), "06") {
+		century := 2000
+		if date.Year()%100 >= pivot {
+			century = 1900
+		}
+		date = time.Date(century+date.Year()%100, date.Month(), date.Day(),
+			date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), date.Location())
+	}
+	return date, nil
+}
+
+// nextID returns the id of the next transaction of the date: its day
+// followed by a counter of the transactions of the day, starting at 1. The
+// counter of a day continues when it comes again after other days, as in
+// overlapping inputs, and it ignores the times of the day, so that the ids
+// are unique. The counter has two digits, and more after the 99th
+// transaction of a day, like 20180301100, so that the ids stay unique,
+// but they don't sort as strings anymore. It also returns the id with the
+// counter of the input only, which is the same in the overlapping inputs.
+func (p *inputParser) nextID(date time.Time) (string, string) {
+	y, m, d := date.Date()
+	day := fmt.Sprintf("%04d%02d%02d", y, m, d)
+	p.counters[day]++
+	p.fileCounters[day]++
+	return fmt.Sprintf("%s%02d", day, p.counters[day]), fmt.Sprintf("%s%02d", day, p.fileCounters[day])
+}
+
+// layoutHasClock checks if the date layout has a time of the day, by
+// formatting two times of the same day with it.
+func layoutHasClock(layout string) bool {
+	day := time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC)
+	return day.Format(layout) != day.Add(13*time.Hour+7*time.Minute+9*time.Second).Format(layout)
+}
+
+// normalizeValue converts a value from the input to the 1234.56 form,
+// including the accounting convention of writing negatives as (12.50).
+func normalizeValue(value string, numbers *numberFormat) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		value = "-" + strings.TrimSpace(value[1:len(value)-1])
+	}
+	return numbers.normalize(value)
+}
+
+// normalizeValue normalizes a value of the input, that is an integer number
+// of cents with -cents.
+func (p *inputParser) normalizeValue(value string) string {
+	value = normalizeValue(value, &p.numbers)
+	if !p.cents {
+		return value
+	}
+	a, err := parseAmount(value)
+	if err != nil || a.scale != 0 {
+		return value
+	}
+	return amount{units: a.units, scale: 2}.String()
+}
+
+// drcrRe matches the trailing DR and CR indicators of the amounts of some
+// banks, like "12.50 DR".
+var drcrRe = regexp.MustCompile(`(?i)\s*\b(DR|CR)$`)
+
+// drcrSplit returns the amount without the DR or CR indicator and the
+// indicator in uppercase, "" if there's none.
+func drcrSplit(value string) (string, string) {
+	value = strings.TrimSpace(value)
+	m := drcrRe.FindStringSubmatch(value)
+	if m == nil {
+		return value, ""
+	}
+	return value[:len(value)-len(m[0])], strings.ToUpper(m[1])
+}
+
+// valueParse returns the withdrawal value of the line and its type: "debit"
+// if it was taken from the debit column, "credit" if from the credit column.
+// With a single amount column, the type comes from the sign, or from the DR
+// and CR indicators with -drcr. Values that are not numbers are errors.
+func (p *inputParser) valueParse(line []string, layout *inputLayout) (value string, typ string, err error) {
+	value, typ = p.columnsValue(line, layout)
+	if _, err := parseAmount(value); err != nil && value != "" {
+		return "", "", fmt.Errorf("invalid amount %q, see -locale", value)
+	}
+	return value, typ, nil
+}
+
+// columnsValue returns the withdrawal value of the line and its type, as
+// read from the columns of the layout by valueParse.
+func (p *inputParser) columnsValue(line []string, layout *inputLayout) (value string, typ string) {
+	drcr := p.drcr || layout.DrCr
+	column := func(col int) string {
+		num := line[col]
+		if drcr {
+			num, _ = drcrSplit(num)
+		}
+		return p.normalizeValue(num)
+	}
+	if layout.AmountColumn >= 0 {
+		if num, indicator := drcrSplit(line[layout.AmountColumn]); drcr && indicator != "" {
+			value = strings.TrimPrefix(p.normalizeValue(num), "-")
+			if indicator == "CR" {
+				return negate(value), "credit"
+			}
+			return value, "debit"
+		}
+		value = column(layout.AmountColumn)
+		if !layout.AmountInverted {
+			value = negate(value)
+		}
+		typ = "debit"
+		if strings.HasPrefix(value, "-") {
+			typ = "credit"
+		}
+		return value, typ
+	}
+	// The statements with debit and credit columns leave the unused one
+	// empty or zero, so a zero debit is a credit, and a zero in both is a
+	// zero credit; a zero debit with an empty credit stays a zero debit.
+	// With -zero-fallback off only an empty debit is a credit, for the
+	// banks that write real zero debits. The debit wins when both columns
+	// have values.
+	typ = "debit"
+	value = column(layout.DebitColumn)
+	if value == "" || (p.zeroFallback && isZero(value)) {
+		if credit := column(layout.CreditColumn); credit != "" || value == "" {
+			typ = "credit"
+			value = negate(credit)
+		}
+	}
+	return value, typ
+}
+
+// truncate limits the text to max runes, the last one being an ellipsis
+// when it's truncated.
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "\u2026"
+}
+
+// newlinesRe matches the line breaks in descriptions, with the spaces around
+// them.
+var newlinesRe = regexp.MustCompile(`[ \t]*[\r\n]+[ \t]*`)
+
+// collapseNewlines replaces the line breaks in the description by spaces.
+func collapseNewlines(description string) string {
+	return newlinesRe.ReplaceAllString(description, " ")
+}
+
+// cleanDescription removes the surrounding whitespace and quotes of a
+// description.
+func cleanDescription(description string) string {
+	description = strings.TrimSpace(description)
+	for len(description) >= 2 {
+		first, last := description[0], description[len(description)-1]
+		if first != last || (first != '"' && first != '\'') {
+			break
+		}
+		description = strings.TrimSpace(description[1 : len(description)-1])
+	}
+	return description
+}
+
+func (p *inputParser) lineParse(line []string, layout *inputLayout) (transaction, error) {
+	if len(line) < layout.columns() {
+		return transaction{}, fmt.Errorf("line with %d columns, %s needs %d", len(line), layout.Name, layout.columns())
+	}
+	dateText := line[layout.DateColumn]
+	if p.months != nil {
+		dateText = englishMonths(dateText, layout.DateLayout, p.months)
+	}
+	date, err := ymdParse(dateText, layout.DateLayout, p.location, p.centuryPivot)
+	if err != nil {
+		return transaction{}, err
+	}
+	value, typ, err := p.valueParse(line, layout)
+	if err != nil {
+		return transaction{}, err
+	}
+	rawValue := ""
+	switch {
+	case layout.AmountColumn >= 0:
+		rawValue = line[layout.AmountColumn]
+	case typ == "debit":
+		rawValue = line[layout.DebitColumn]
+	default:
+		rawValue = line[layout.CreditColumn]
+	}
+	balance := ""
+	if layout.BalanceColumn >= 0 {
+		balance = p.normalizeValue(line[layout.BalanceColumn])
+	}
+	currency := ""
+	if layout.CurrencyColumn >= 0 && layout.CurrencyColumn < len(line) {
+		currency = strings.TrimSpace(line[layout.CurrencyColumn])
+	}
+	srcAccount := ""
+	if p.srcCol >= 0 && p.srcCol < len(line) {
+		srcAccount = strings.TrimSpace(line[p.srcCol])
+	}
+	description := p.description(line[layout.DescriptionColumn])
+	id, fileID := p.nextID(date)
+	return transaction{
+		ID:          id,
+		fileID:      fileID,
+		Date:        date,
+		Description: description,
+		Value:       value,
+		RawValue:    strings.TrimSpace(rawValue),
+		Balance:     balance,
+		Currency:    currency,
+		SrcAccount:  srcAccount,
+		Type:        typ,
+		Fields:      line,
+		timed:       layoutHasClock(layout.DateLayout),
+	}, nil
+}
+
+// description cleans up a description of the input.
+func (p *inputParser) description(description string) string {
+	return p.descriptions.clean(description)
+}
+
+// descriptionCleaner normalizes the descriptions as set by -no-trim,
+// -normalize-unicode, -collapse-newlines and -strip-numbers, both the ones
+// of the inputs and the ones given in the command line.
+type descriptionCleaner struct {
+	noTrim           bool
+	nfc              bool // normalize the descriptions to NFC
+	collapseNewlines bool
+	stripNumbers     *regexp.Regexp // of the references removed from the descriptions
+}
+
+func newDescriptionCleaner(opts *options) descriptionCleaner {
+	return descriptionCleaner{noTrim: opts.noTrim, nfc: opts.normalizeUnicode,
+		collapseNewlines: opts.collapseNewlines, stripNumbers: opts.stripNumbers}
+}
+
+func (c descriptionCleaner) clean(description string) string {
+	if c.nfc {
+		description = norm.NFC.String(description)
+	}
+	if c.collapseNewlines {
+		description = collapseNewlines(description)
+	}
+	if !c.noTrim {
+		description = cleanDescription(description)
+	}
+	if c.stripNumbers != nil {
+		description = c.stripNumbers.ReplaceAllString(description, "")
+	}
+	return description
+}
+
+// parseResult is a transaction parsed from an input, or the error that
+// stopped the parsing.
+type parseResult struct {
+	t   *transaction
+	err error
+}
+
+// pipelineError has the first error of the stages of the pipeline of
+// transactions, which close their output channels when they fail, so that
+// the consumer gets it after its loop ends.
+type pipelineError struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (e *pipelineError) set(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *pipelineError) get() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// inputParser holds the state shared by all the inputs of a run.
+type inputParser struct {
+	out          chan<- parseResult
+	counters     map[string]int // of the transactions of each day, for the ids
+	fileCounters map[string]int // of the transactions of each day of the input
+	numbers      numberFormat
+	bank         *inputLayout // forced by -bank
+	overrides    layoutOverrides
+	centuryPivot int
+	drcr         bool
+	cents        bool
+	months       []string // names of the months of -date-locale
+	descriptions descriptionCleaner
+	srcCol       int    // of the source account, -1 for none
+	informat     string // "bank", "bankcsv" or "json"
+	dateFormat   string // of the bankcsv input
+	jsonFields   jsonInputFields
+	skipBad      bool
+	maxErrs      int
+	errs         int
+	recordSep    byte           // of -record-sep, 0 for newlines
+	location     *time.Location // of the dates, from -tz
+	idScopeFile  bool           // restart the ids of each day in each input
+	zeroFallback bool           // read the credit column when the debit is zero
+}
+
+func newInputParser(out chan<- parseResult, opts *options) *inputParser {
+	return &inputParser{out: out, counters: map[string]int{}, numbers: opts.numbers, bank: opts.bank,
+		descriptions: newDescriptionCleaner(opts), informat: opts.informat, dateFormat: opts.outputDateFormat,
+		srcCol: opts.srcAccountColumn, skipBad: opts.skipBadLines,
+		maxErrs: opts.maxErrors, overrides: opts.overrides, drcr: opts.drcr,
+		cents: opts.cents, months: opts.months, centuryPivot: opts.centuryPivot,
+		recordSep: opts.recordSep, jsonFields: opts.jsonFields, location: opts.dateLocation(),
+		idScopeFile: opts.idScope == "file", zeroFallback: opts.zeroFallback == "on"}
+}
+
+// badLine handles an error parsing a line, returning it unless
+// -skip-bad-lines was given and there were less than -max-errors errors.
+func (p *inputParser) badLine(inputName string, lineNum int, err error) error {
+	if !p.skipBad {
+		return fmt.Errorf("%s:%d: %w", inputName, lineNum, err)
+	}
+	emit(diagnostic{Level: "warning", Msg: "skipping bad line: " + err.Error(), File: inputName, Line: lineNum})
+	p.errs++
+	if p.maxErrs > 0 && p.errs >= p.maxErrs {
+		return fmt.Errorf("too many bad lines (%d), the input or its layout is probably wrong", p.errs)
+	}
+	return nil
+}
+
+// parseCsv parses a statement, detecting its layout from the headers unless
+// a bank was given.
+func (p *inputParser) parseCsv(inputName string, input io.Reader) error {
+	inputBuf := bufio.NewReader(input)
+	inputCsv := csv.NewReader(inputBuf)
+	// Concatenated statements have header rows in the middle, possibly with a
+	// different number of columns; lineParse checks the columns instead.
+	inputCsv.FieldsPerRecord = -1
+	// Without -bank, the layout comes from the headers; the lines before
+	// the first one can't be parsed, as their signs would be guessed.
+	var layout *inputLayout
+	if p.bank != nil {
+		layout = p.overrides.apply(p.bank)
+	}
+	for lineNum := 1; ; lineNum++ {
+		line, err := inputCsv.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			if err := p.badLine(inputName, lineNum, err); err != nil {
+				return err
+			}
+			continue
+		}
+		// Each header resets the layout, as a credit card statement can be
+		// concatenated with a current account one; -bank only selects the
+		// layout of the lines before the first header.
+		if p.bank != nil && p.bank.isHeader(line) {
+			layout = p.overrides.apply(p.bank)
+			continue
+		} else if detected := detectLayout(line); detected != nil {
+			layout = p.overrides.apply(detected)
+			continue
+		}
+		if layout == nil {
+			if err := p.badLine(inputName, lineNum, errors.New("line before any recognized header, see -bank and -list-banks")); err != nil {
+				return err
+			}
+			continue
+		}
+		t, err := p.lineParse(line, layout)
+		if err != nil {
+			if err := p.badLine(inputName, lineNum, err); err != nil {
+				return err
+			}
+			continue
+		}
+		t.File = inputName
+		t.Line = lineNum
+		p.out <- parseResult{t: &t}
+	}
+	return nil
+}
+
+// parseZip parses the csv files in a zip archive in name order.
+func (p *inputParser) parseZip(inputName string) error {
+	archive, err := zip.OpenReader(filepath.Clean(inputName))
+	if err != nil {
+		return err
+	}
+	defer archive.Close() // nolint: errcheck
+	return p.parseZipArchive(inputName, &archive.Reader)
+}
+
+// parseZipStdin parses a zip archive piped to the stdin, which is read
+// whole as zip archives have their directory at the end.
+func (p *inputParser) parseZipStdin(input io.Reader) error {
+	dat, err := ioutil.ReadAll(input)
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(dat), int64(len(dat)))
+	if err != nil {
+		return fmt.Errorf("-: %w", err)
+	}
+	return p.parseZipArchive("-", archive)
+}
+
+func (p *inputParser) parseZipArchive(inputName string, archive *zip.Reader) error {
+	files := make([]*zip.File, 0, len(archive.File))
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(file.Name), ".csv") {
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	for _, file := range files {
+		entry, err := file.Open()
+		if err != nil {
+			return err
+		}
+		if err := p.parseInput(inputName+":"+file.Name, entry); err != nil {
+			entry.Close() // nolint: errcheck
+			return err
+		}
+		if err := entry.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *inputParser) parseFile(inputName string) error {
+	if strings.EqualFold(filepath.Ext(inputName), ".zip") {
+		return p.parseZip(inputName)
+	}
+	inputFd, err := os.Open(filepath.Clean(inputName))
+	if err != nil {
+		return err
+	}
+	defer inputFd.Close() // nolint: errcheck
+	return p.parseInput(inputName, inputFd)
+}
+
+// recordSepReader replaces the -record-sep separator of the records by
+// newlines, for the csv reader.
+type recordSepReader struct {
+	r   io.Reader
+	sep byte
+}
+
+func (r recordSepReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	for i := 0; i < n; i++ {
+		if b[i] == r.sep {
+			b[i] = '\n'
+		}
+	}
+	return n, err
+}
+
+// parseInput parses the input in the -informat.
+func (p *inputParser) parseInput(inputName string, input io.Reader) error {
+	if p.idScopeFile {
+		p.counters = map[string]int{}
+	}
+	p.fileCounters = map[string]int{}
+	if p.recordSep != 0 {
+		input = recordSepReader{r: input, sep: p.recordSep}
+	}
+	switch p.informat {
+	case "bankcsv":
+		return p.parseBankcsv(inputName, input)
+	case "json":
+		return p.parseJSON(inputName, input)
+	}
+	return p.parseCsv(inputName, input)
+}
+
+// parseReader parses a statement from any reader, like a strings.Reader in
+// benchmarks, with the layout, or detecting it from the headers when nil.
+// The parsing stops at the first error, which is the last result.
+func parseReader(input io.Reader, layout *inputLayout, opts *options) <-chan parseResult {
+	out := make(chan parseResult)
+	go func() {
+		defer close(out)
+		p := newInputParser(out, opts)
+		if layout != nil {
+			p.bank = layout
+		}
+		if err := p.parseInput("-", input); err != nil {
+			out <- parseResult{err: err}
+		}
+	}()
+	return out
+}
+
+// inputsParse parses the inputs in order into a single channel, the fan-in
+// of the pipeline, so that the transactions reach the output one at a time
+// and in the order of the inputs and of their lines. The parsing stops at
+// the first error, set in errs.
+func inputsParse(inputNames []string, opts *options, errs *pipelineError) <-chan *transaction {
+	results := make(chan parseResult)
+	go func() {
+		defer close(results)
+		p := newInputParser(results, opts)
+		for _, inputName := range inputNames {
+			// The stdin is parsed by the same parser as the files, so that
+			// the counters of the ids continue in it.
+			var err error
+			switch {
+			case inputName == "-" && opts.stdinFormat == "zip":
+				err = p.parseZipStdin(os.Stdin)
+			case inputName == "-":
+				err = p.parseInput("-", os.Stdin)
+			default:
+				err = p.parseFile(inputName)
+			}
+			if err != nil {
+				var pathErr *os.PathError
+				if opts.continueOnFileError && errors.As(err, &pathErr) {
+					warnf("skipping %s: %s", inputName, err)
+					opts.failedInputs = append(opts.failedInputs, inputName)
+					continue
+				}
+				results <- parseResult{err: err}
+				return
+			}
+		}
+	}()
+	out := make(chan *transaction)
+	go func() {
+		defer close(out)
+		for r := range results {
+			if r.err != nil {
+				errs.set(r.err)
+				return
+			}
+			out <- r.t
+		}
+	}()
+	return out
+}
+
+// drain discards the rest of the transactions of a channel, so that the
+// stages before it can finish after a later one stops.
+func drain(in <-chan *transaction) {
+	for range in {
+	}
+}
+
+// coalesceKey is what identifies the rows of a single purchase that were
+// split by the bank.
+func coalesceKey(t *transaction) string {
+	return t.Date.Format("2006-01-02") + "," + lookupKey(t.Description)
+}
+
+// coalesce merges the consecutive transactions with the same date and
+// description, summing their values. It stops at the first invalid value,
+// set in errs.
+func coalesce(in <-chan *transaction, errs *pipelineError) <-chan *transaction {
+	out := make(chan *transaction)
+	go func() {
+		defer close(out)
+		var cur *transaction
+		var sum amount
+		merged := 0
+		flush := func() {
+			if cur == nil {
+				return
+			}
+			if merged > 0 {
+				infof("%s:%d: coalesced %d transactions of %s", cur.File, cur.Line, merged+1, cur.Description)
+				cur.Value = sum.String()
+				cur.Type = "debit"
+				if sum.Sign() < 0 {
+					cur.Type = "credit"
+				}
+			}
+			out <- cur
+		}
+		for t := range in {
+			value, err := parseAmount(t.Value)
+			if err != nil {
+				errs.set(fmt.Errorf("%s:%d: invalid value %q: %w", t.File, t.Line, t.Value, err))
+				drain(in)
+				return
+			}
+			if cur != nil && coalesceKey(cur) == coalesceKey(t) {
+				sum = sum.Add(value)
+				cur.Balance = t.Balance
+				merged++
+				continue
+			}
+			flush()
+			cur, sum, merged = t, value, 0
+		}
+		flush()
+	}()
+	return out
+}
+
+// verifyBalance checks the balances reported in the inputs against the
+// running total of the values, starting from the opening balance or from the
+// first balance reported. The total is resynchronized after a divergence, so
+// that each one is only reported once.
+func verifyBalance(in <-chan *transaction, openings map[string]string, opening string, srcAccount string) <-chan *transaction {
+	out := make(chan *transaction)
+	go func() {
+		defer close(out)
+		running := map[string]amount{}
+		known := map[string]bool{}
+		for account, balance := range openings {
+			running[account], _ = parseAmount(balance)
+			known[account] = true
+		}
+		if _, ok := openings[srcAccount]; !ok && opening != "" {
+			running[srcAccount], _ = parseAmount(opening)
+			known[srcAccount] = true
+		}
+		for t := range in {
+			account := t.SrcAccount
+			if account == "" {
+				account = srcAccount
+			}
+			if _, ok := known[account]; !ok && len(openings) > 0 {
+				// The accounts missing from the OpeningBalances start at zero.
+				known[account] = true
+			}
+			value, err := parseAmount(t.Value)
+			if err != nil {
+				warnTransactionf(t, "invalid value %q, balance not verified", t.Value)
+				known[account] = false
+			}
+			running[account] = running[account].Sub(value)
+			if t.Balance != "" {
+				balance, err := parseAmount(t.Balance)
+				if err != nil {
+					warnTransactionf(t, "invalid balance %q", t.Balance)
+				} else {
+					if known[account] && !running[account].Sub(balance).IsZero() {
+						warnTransactionf(t, "balance %s of %s differs from the expected %s", balance, account, running[account])
+					}
+					running[account], known[account] = balance, true
+				}
+			}
+			out <- t
+		}
+	}()
+	return out
+}
+
+// state file: ///////////////////////////////////////////////////////////////
+
+// The state file has the date of the last transaction processed, so that
+// runs with -state only output the transactions after it.
+
+const stateDateFormat = "2006-01-02"
+
+// stateRead returns the date in the state file, in the location of the
+// dates, or the zero time if the file doesn't exist yet.
+func stateRead(stateName string, location *time.Location) (time.Time, error) {
+	dat, err := ioutil.ReadFile(filepath.Clean(stateName))
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	date, err := time.ParseInLocation(stateDateFormat, strings.TrimSpace(string(dat)), location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid state file %s: %w", stateName, err)
+	}
+	return date, nil
+}
+
+func stateWrite(stateName string, date time.Time) error {
+	if date.IsZero() {
+		return nil
+	}
+	return ioutil.WriteFile(stateName, []byte(date.Format(stateDateFormat)+"\n"), 0600)
+}
+
+// processor //////////////////////////////////////////////////////////////////
+
+// createOutput creates the output file. Existing non-regular files, like
+// named pipes and devices, are opened for writing without truncation.
+func createOutput(name string) (*os.File, error) {
+	info, err := os.Stat(name)
+	if err == nil && !info.Mode().IsRegular() {
+		return os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	}
+	return os.Create(name)
+}
+
+// outputCountFormat counts the transactions instead of writing them, for
+// -count, so that the number printed is the one of the transactions that
+// the run would write, after all the filters. The number of each input is
+// also printed when verbose.
+type outputCountFormat struct {
+	out     io.Writer
+	total   int
+	files   []string
+	perFile map[string]int
+}
+
+func newOutputCountFormat() outputFormat {
+	return &outputCountFormat{perFile: map[string]int{}}
+}
+
+func (o *outputCountFormat) Init(out io.Writer) error {
+	o.out = out
+	return nil
+}
+
+func (o *outputCountFormat) Add(t *transaction) error {
+	if _, ok := o.perFile[t.File]; !ok {
+		o.files = append(o.files, t.File)
+	}
+	o.perFile[t.File]++
+	o.total++
+	return nil
+}
+
+func (o *outputCountFormat) Finish() error {
+	for _, file := range o.files {
+		infof("%s: %d", file, o.perFile[file])
+	}
+	_, err := fmt.Fprintln(o.out, o.total)
+	return err
+}
+
+// applyConfigOutput sets the flags in the Output of the config that were
+// not given in the command line, which take precedence. The config is only
+// peeked at here, errors loading it are reported when it's loaded.
+func applyConfigOutput(opts *options, explaining bool) error {
+	var cfg config
+	var err error
+	args := flag.Args()
+	switch {
+	case opts.configEnv != "":
+		cfg, err = configFromEnv(opts.configEnv)
+	case opts.configDir != "":
+		cfg, err = configFromDir(opts.configDir)
+	case len(args) > 0 && (explaining || isFileArg(args[0])):
+		cfg, err = configFromJSON(&args[0], opts.configTimeout)
+	case len(args) > 1:
+		cfg, err = configFromJSON(&args[1], opts.configTimeout)
+	}
+	if err != nil {
+		return nil
+	}
+	visited := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { visited[f.Name] = true })
+	for name, value := range cfg.Output {
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %q in the Output of the config", name)
+		}
+		if visited[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %q in the Output of the config: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// outputFile is an output file or stdout, compressed with gzip or not.
+type outputFile struct {
+	name   string
+	fd     *os.File
+	gz     *gzip.Writer
+	closed bool
+}
+
+// openOutput opens the output file, "-" being stdout; it's compressed when
+// forced or when the name ends in .gz.
+func openOutput(name string, forceGzip bool) (*outputFile, error) {
+	f := outputFile{name: name, fd: os.Stdout}
+	if name != "-" {
+		var err error
+		f.fd, err = createOutput(name)
+		if err != nil {
+			return nil, fmt.Errorf("error creating file: %w", err)
+		}
+	}
+	if forceGzip || strings.HasSuffix(name, ".gz") {
+		f.gz = gzip.NewWriter(f.fd)
+	}
+	return &f, nil
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	if f.gz != nil {
+		return f.gz.Write(p)
+	}
+	return f.fd.Write(p)
+}
+
+// close closes the file, only once, so that it can also be deferred for
+// the errors.
+func (f *outputFile) close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			return fmt.Errorf("error closing gzip stream: %w", err)
+		}
+	}
+	if f.fd != os.Stdout {
+		if err := f.fd.Close(); err != nil {
+			return fmt.Errorf("error closing %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// isFileArg checks if a command line argument is an existing file, not a
+// directory, or a config url, which means that the optional srcAccount was
+// omitted.
+func isFileArg(arg string) bool {
+	if isURL(arg) {
+		return true
+	}
+	info, err := os.Stat(arg)
+	return err == nil && !info.IsDir()
+}
+
+// checkInputAges fails if an input file was modified more than maxAge ago,
+// which is usually a download that failed and left the previous file.
+func checkInputAges(inputNames []string, maxAge time.Duration) error {
+	for _, inputName := range inputNames {
+		if inputName == "-" {
+			continue
+		}
+		info, err := os.Stat(inputName)
+		if err != nil {
+			return err
+		}
+		if age := time.Since(info.ModTime()); age > maxAge {
+			return fmt.Errorf("%s is stale: modified %s ago, more than the -max-age %s", inputName, age.Round(time.Second), maxAge)
+		}
+	}
+	return nil
+}
+
+func processCsvs(srcAccount *string, jsonName *string, opts *options, inputNames []string) error {
+	cfg, err := loadConfig(jsonName, opts)
+	if err != nil {
+		return err
+	}
+	if opts.bank == nil && cfg.InputFormat != nil {
+		opts.bank = cfg.InputFormat
+	}
+	if opts.maxAge > 0 {
+		if err := checkInputAges(inputNames, opts.maxAge); err != nil {
+			return err
+		}
+	}
+	if opts.ruleStats {
+		cfg.ruleHits = make([]int, len(cfg.AccountFromDescription))
+	}
+	if *srcAccount == "" {
+		if cfg.SrcAccount == "" && opts.srcAccountColumn < 0 && opts.informat != "json" {
+			return errors.New("no srcAccount given in the arguments or in the SrcAccount of the config")
+		}
+		srcAccount = &cfg.SrcAccount
+	}
+	sinceDate := opts.sinceDate
+	var stateDate time.Time
+	if opts.stateName != "" {
+		stateDate, err = stateRead(opts.stateName, opts.dateLocation())
+		if err != nil {
+			return err
+		}
+		if stateDate.After(sinceDate) {
+			sinceDate = stateDate
+		}
+	}
+	var rates *exchangeRates
+	if opts.baseCurrency != "" {
+		rates, err = loadRates(opts)
+		if err != nil {
+			return err
+		}
+	}
+	var o outputFormat
+	var outFile *outputFile
+	src := opts.prefixAccount(opts.srcAccountPrefix, *srcAccount)
+	if opts.writesFormat("beancount") {
+		if err := checkBeancountAccount(src); err != nil {
+			return fmt.Errorf("source account: %w", err)
+		}
+	}
+	var diffOutput bytes.Buffer
+	if opts.count {
+		o = newOutputCountFormat()
+		if err := o.Init(os.Stdout); err != nil {
+			return err
+		}
+	} else if opts.diffName != "" {
+		// Nothing is written, the output is compared with the existing one.
+		o = outputFormats[opts.format](opts, &cfg, src)
+		if err := o.Init(&diffOutput); err != nil {
+			return err
+		}
+	} else if opts.outDir != "" {
+		o = newOutputDirFormat(opts, &cfg, src)
+		if err := o.Init(nil); err != nil {
+			return err
+		}
+	} else if isOutputTemplate(opts.outputName) {
+		o = newOutputTemplateFormat(opts, &cfg, src)
+	} else {
+		outFile, err = openOutput(opts.outputName, opts.gzip)
+		if err != nil {
+			return err
+		}
+		defer outFile.close() // nolint: errcheck
+		o = outputFormats[opts.format](opts, &cfg, src)
+	}
+	if opts.sortBy != nil {
+		o = newOutputSortFormat(o, opts.sortBy, cfg.AccountOrder)
+	}
+	if opts.flushEvery > 0 {
+		o = newOutputFlushFormat(o, opts.flushEvery, outFile)
+	}
+	if outFile != nil {
+		// The template and directory formats open their own files instead.
+		if err := o.Init(outFile); err != nil {
+			return err
+		}
+	}
+	stats := newRunStats()
+	// The stats are also written when the run fails, with the counts of
+	// the transactions processed before the error.
+	statsWritten := false
+	defer func() {
+		if opts.statsName != "" && !statsWritten {
+			if err := stats.write(opts.statsName); err != nil {
+				warnf("error writing the stats: %s", err)
+			}
+		}
+	}()
+	var net amount // of the source accounts, for -reconcile
+	unmatched := unmatchedReport{}
+	sinceIDSeen := opts.sinceID == ""
+	var errs pipelineError
+	transactions := inputsParse(inputNames, opts, &errs)
+	if opts.coalesce {
+		transactions = coalesce(transactions, &errs)
+	}
+	if opts.verifyBalance {
+		transactions = verifyBalance(transactions, cfg.OpeningBalances, opts.openingBalance, *srcAccount)
+	}
+	idPrefix := opts.sourceIDPrefix(*srcAccount)
+	var dedup *deduper
+	if opts.dedupBy != nil {
+		dedup = newDeduper(opts.dedupBy, opts.dedupWindow, opts.dedupInputs)
+		if opts.dedupKeepLast {
+			transactions = dedup.keepLast(transactions, &stats.Duplicates)
+			dedup = nil
+		}
+	}
+	// The stages are drained when returning early, so that they finish.
+	defer drain(transactions)
+	for t := range transactions {
+		stats.Total++
+		if account, ok := cfg.SrcAccounts[t.SrcAccount]; ok && t.SrcAccount != "" {
+			t.SrcAccount = account
+		}
+		// The ids read back by -informat bankcsv already have their prefixes.
+		switch {
+		case opts.informat == "bankcsv":
+			if t.rowAccount != "" && t.rowAccount != src && t.rowAccount != *srcAccount {
+				return fmt.Errorf("%s:%d: row of %s instead of the source account %s, -informat bankcsv can only read the output of -legs both or src", t.File, t.Line, t.rowAccount, *srcAccount)
+			}
+		case t.SrcAccount != "":
+			t.ID = opts.sourceIDPrefix(t.SrcAccount) + t.ID
+			t.fileID = opts.sourceIDPrefix(t.SrcAccount) + t.fileID
+		default:
+			t.ID = idPrefix + t.ID
+			t.fileID = idPrefix + t.fileID
+		}
+		if !sinceIDSeen {
+			sinceIDSeen = t.ID == opts.sinceID
+			stats.Skipped++
+			continue
+		}
+		if !sinceDate.IsZero() && !t.Date.After(sinceDate) {
+			stats.Skipped++
+			continue
+		}
+		if opts.fileDates != nil && !opts.fileDates.contains(t) {
+			warnTransactionf(t, "skipping transaction of %s outside of the period of the file name", t.Date.Format("2006-01-02"))
+			stats.Skipped++
+			continue
+		}
+		if dedup != nil && dedup.duplicate(t) {
+			infof("%s:%d: skipping duplicate of %s", t.File, t.Line, t.Description)
+			stats.Duplicates++
+			continue
+		}
+		stats.addDate(t.Date)
+		if t.Date.After(stateDate) {
+			stateDate = t.Date
+		}
+		if opts.dropZero && isZero(t.Value) {
+			infof("%s:%d: dropping zero-value %s", t.File, t.Line, t.Description)
+			stats.Zero++
+			continue
+		}
+		if opts.reconcile != "" {
+			value, err := parseAmount(t.Value)
+			if err != nil {
+				return fmt.Errorf("%s:%d: can't reconcile the value %q: %w", t.File, t.Line, t.Value, err)
+			}
+			net = net.Sub(value)
+		}
+		if err := opts.checkCurrency(t); err != nil {
+			return err
+		}
+		if rates != nil {
+			if err := rates.convert(t); err != nil {
+				return err
+			}
+		}
+		if t.SrcAccount == "" {
+			if *srcAccount == "" {
+				return fmt.Errorf("%s:%d: no source account in the -srcaccount-col column", t.File, t.Line)
+			}
+			t.SrcAccount = *srcAccount
+		}
+		if opts.keepSourceLine {
+			t.Source = csvJoin(t.Fields)
+		}
+		t.Payee = cfg.payee(t.Description)
+		found, err := cfg.assign(t)
+		if err != nil {
+			return err
+		}
+		if opts.hook != "" {
+			if err := runHook(opts.hook, t, opts.outputDateFormat, opts.dateLocation()); err != nil {
+				warnTransactionf(t, "%s", err)
+			}
+			found = t.Account != "" || len(t.Splits) > 0
+		}
+		if !found {
+			if account := cfg.defaultAccount(t); account != "" {
+				t.Account = account
+				found = true
+			}
+		}
+		if found {
+			cfg.checkSigns(t)
+			t.SrcAccount = opts.prefixAccount(opts.srcAccountPrefix, t.SrcAccount)
+			t.Account = opts.prefixAccount(opts.accountPrefix, cfg.alias(t.Account))
+			for i := range t.Splits {
+				t.Splits[i].Account = opts.prefixAccount(opts.accountPrefix, cfg.alias(t.Splits[i].Account))
+			}
+			// The zero values are neither debits nor credits, and are
+			// kept, as they are only dropped by -drop-zero.
+			if sign := valueSign(t); opts.only != "" && sign != "" && sign+"s" != opts.only {
+				stats.Skipped++
+				continue
+			}
+			if opts.filter != nil && !opts.filter.match(t) {
+				stats.Skipped++
+				continue
+			}
+			if opts.outputLocation != nil && t.timed {
+				t.Date = t.Date.In(opts.outputLocation)
+			}
+			if opts.maxDescLen > 0 {
+				t.Description = truncate(t.Description, opts.maxDescLen)
+			}
+			if err := o.Add(t); err != nil {
+				return err
+			}
+			stats.addMatched(t)
+		} else {
+			if opts.strict {
+				return fmt.Errorf("%s:%d: could not assign account to %s", t.File, t.Line, t.Description)
+			}
+			if !opts.noWarnUnmatched {
+				warnTransactionf(t, "could not assign account to %s", t.Description)
+			}
+			stats.Unmatched++
+			unmatched.add(t)
+		}
+	}
+	if err := errs.get(); err != nil {
+		return err
+	}
+	if opts.noWarnUnmatched && stats.Unmatched > 0 {
+		warnf("%d transactions unmatched; run with -unmatched-report to inspect", stats.Unmatched)
+	}
+	if opts.ruleStats {
+		if err := writeRuleStats(os.Stderr, &cfg); err != nil {
+			return err
+		}
+	}
+	if opts.tree {
+		if err := newAccountTree(stats, opts.accountSeparator).write(os.Stderr, 0); err != nil {
+			return err
+		}
+	}
+	if opts.statsName != "" {
+		statsWritten = true
+		if err := stats.write(opts.statsName); err != nil {
+			return err
+		}
+	}
+	if opts.unmatchedName != "" {
+		if err := unmatched.write(opts.unmatchedName); err != nil {
+			return err
+		}
+	}
+	if opts.skeletonName != "" {
+		if err := unmatched.writeConfig(opts.skeletonName); err != nil {
+			return err
+		}
+	}
+	if err := o.Finish(); err != nil {
+		return err
+	}
+	if !sinceIDSeen {
+		warnf("transaction %s given in -since-id not found, nothing written", opts.sinceID)
+	}
+	if outFile != nil {
+		if err := outFile.close(); err != nil {
+			return err
+		}
+	}
+	if stats.Zero > 0 {
+		infof("dropped %d zero-value transactions", stats.Zero)
+	}
+	if stats.Matched == 0 && stats.Total > stats.Skipped+stats.Zero && !opts.allowEmpty {
+		return fmt.Errorf("none of the %d transactions could be assigned, nothing written (see -allow-empty)", stats.Total-stats.Skipped-stats.Zero)
+	}
+	if opts.reconcile != "" {
+		target, _ := parseAmount(opts.reconcile)
+		tolerance, _ := parseAmount(opts.tolerance)
+		off := net.Sub(target)
+		if off.Sign() < 0 {
+			off = off.Neg()
+		}
+		if off.Sub(tolerance).Sign() > 0 {
+			return fmt.Errorf("the net change of the transactions is %s, not the %s of -reconcile", net, opts.reconcile)
+		}
+	}
+	if len(opts.failedInputs) > 0 {
+		return fmt.Errorf("skipped %d inputs that couldn't be read: %s", len(opts.failedInputs), strings.Join(opts.failedInputs, ", "))
+	}
+	if opts.diffName != "" {
+		return writeDiff(os.Stdout, opts.diffName, diffOutput.Bytes(), opts.showDiff)
+	}
+	if opts.stateName != "" && !opts.count {
+		if err := stateWrite(opts.stateName, stateDate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	// Diagnostics always go to stderr, even when the output is stdout.
+	log.SetOutput(os.Stderr)
+	flag.CommandLine.SetOutput(os.Stderr)
+	var opts options
+	flag.StringVar(&opts.outputName, "o", "-", "output file")
+	flag.IntVar(&opts.flushEvery, "flush-every", 0, "flush the output every this many transactions, for the readers of pipes, instead of only at the end")
+	flag.StringVar(&opts.outDir, "out-dir", "", "write the output to files named transactions with the extensions of the formats in this directory, with -f having a comma-separated list of formats")
+	flag.BoolVar(&opts.gzip, "gzip", false, "compress the output with gzip (implied by a .gz output file)")
+	flag.StringVar(&opts.outputDateFormat, "output-date-format", "2006-01-02", "date format used in the output, as a go time layout")
+	flag.StringVar(&opts.legs, "legs", "both", "legs of each transaction to output: both, src or dst")
+	flag.StringVar(&opts.csvStyle, "csv-style", "withdrawal", "csv values: withdrawal, with a balancing row per transaction, or signed, with a single row of signed amounts")
+	fieldsList := flag.String("fields", "", "comma-separated fields of the csv and json outputs, in order, from "+strings.Join(outputFields, ","))
+	flag.BoolVar(&opts.typeColumn, "type-column", false, "add a column with the transaction type (debit or credit)")
+	flag.StringVar(&opts.configDir, "config-dir", "", "merge all json config files in this directory instead of using a config file argument")
+	flag.StringVar(&opts.configEnv, "config-env", "", "read the json config from this environment variable instead of using a config file argument")
+	flag.DurationVar(&opts.configTimeout, "config-timeout", 30*time.Second, "timeout to fetch a config given as an http(s) url")
+	flag.StringVar(&opts.lookupName, "lookup", "", "csv file with merchant,account lines that assign accounts to the exact descriptions, before the rules")
+	flag.StringVar(&opts.dateLocale, "date-locale", "", "language of the month names in the dates of the inputs, like es for 3 Ene 2018 (default english)")
+	tz := flag.String("tz", "UTC", "time zone of the dates of the inputs with times of the day, like Europe/Dublin or Local")
+	outputTZ := flag.String("output-tz", "", "time zone of the output dates with times of the day, converted from the -tz, like UTC (default the -tz)")
+	flag.StringVar(&opts.locale, "locale", "", "locale of the numbers in the inputs, like de-DE for 1.234,56 (default accepts 1234.56)")
+	flag.StringVar(&opts.idScope, "id-scope", "run", "scope of the counters of the transaction ids of each day: run, continuing across the inputs, or file, restarting in each input")
+	flag.StringVar(&opts.sinceID, "since-id", "", "only output the transactions after the one with this id")
+	dateFromFilename := flag.String("date-from-filename", "", "regex with year and month groups, like (?P<year>\\d{4})-(?P<month>\\d{2}), that extracts the period of each input from its name, skipping the transactions outside of it")
+	flag.StringVar(&opts.only, "only", "", "only output the debits, with positive withdrawal values, or the credits, keeping the zero values of both")
+	filterFlag := flag.String("filter", "", "only output the transactions that match this expression, like 'value > 100 && account =~ \"^Expenses\"'")
+	sinceDate := flag.String("since-date", "", "only output the transactions after this date (yyyy-mm-dd)")
+	flag.StringVar(&opts.diffName, "diff", "", "compare the csv output of the run with this existing output file, printing the number of transactions added, removed and changed, without writing anything")
+	flag.BoolVar(&opts.showDiff, "show-diff", false, "also print the ids of the transactions added (+), removed (-) and changed (~) by -diff")
+	flag.StringVar(&opts.skeletonName, "emit-config-from-unmatched", "", "write a skeleton json config with a rule for each unmatched description, with an empty Account to fill in, to this file")
+	flag.StringVar(&opts.hook, "hook", "", "shell command that gets each transaction as a json object in its stdin and writes it back, possibly changed, to its stdout")
+	flag.BoolVar(&opts.ruleStats, "rule-stats", false, "print the number of transactions assigned by each rule, including the ones that assigned none, to stderr")
+	flag.BoolVar(&opts.tree, "tree", false, "print the tree of the accounts that got transactions, with their counts and totals, to stderr")
+	flag.StringVar(&opts.statsName, "stats-out", "", "write the counts, account totals and date range of the run to this json file")
+	flag.BoolVar(&opts.noWarnUnmatched, "no-warn-unmatched", false, "print the number of unmatched transactions at the end instead of a warning for each one")
+	flag.StringVar(&opts.unmatchedName, "unmatched-report", "", "write the unmatched descriptions with their counts and totals to this json file")
+	flag.StringVar(&opts.stateName, "state", "", "state file with the date of the last transaction processed, updated on success")
+	flag.BoolVar(&opts.utf8BOM, "utf8-bom", false, "start the csv and table outputs with a UTF-8 byte order mark, for excel")
+	flag.BoolVar(&opts.crlf, "crlf", false, "use \\r\\n line endings in the output")
+	flag.StringVar(&opts.quote, "quote", "minimal", "quoting of the csv fields: minimal or all")
+	flag.StringVar(&opts.format, "f", "auto", "output format: csv, ledger, hledger, beancount, json, jsonl, table, ynab, or auto to infer it from the output file extension")
+	flag.StringVar(&opts.format, "format", "auto", "same as -f")
+	flag.BoolVar(&opts.emptyAsNull, "empty-as-null", false, "write the empty fields, like the account of unassigned transactions, as null instead of \"\" in the json formats")
+	flag.BoolVar(&opts.ynabAmount, "ynab-amount", false, "write the values in a single Amount column, positive for the deposits, instead of the Outflow and Inflow columns in the ynab format")
+	flag.BoolVar(&opts.jsonNumbers, "json-numbers", false, "write the values as numbers instead of strings in the json formats")
+	flag.StringVar(&opts.symbol.symbol, "currency-symbol", "$", "currency symbol of the amounts in the ledger and table formats, like $ or EUR, or \"\" for none")
+	symbolPosition := flag.String("symbol-position", "before", "position of the -currency-symbol: before or after the amounts")
+	flag.BoolVar(&opts.groupOutput, "group-output", false, "group the thousands of the amounts in the table format, with the separators of the -locale")
+	flag.BoolVar(&opts.pretty, "pretty", false, "indent the json format output, which is then written at the end")
+	flag.BoolVar(&opts.keepRawValue, "keep-raw-value", false, "add a raw_value column with the amount as written in the input")
+	flag.BoolVar(&opts.keepSourceLine, "keep-source-line", false, "add a column with the original input line")
+	recordSep := flag.String("record-sep", "", "single character that separates the records of the inputs instead of the newlines, like ~")
+	flag.BoolVar(&opts.dropZero, "drop-zero", false, "drop the transactions with a zero value, like informational authorizations")
+	flag.BoolVar(&opts.continueOnFileError, "continue-on-file-error", false, "skip the inputs that can't be opened or read with a warning, processing the others, and fail at the end")
+	flag.DurationVar(&opts.maxAge, "max-age", 0, "fail if an input file was modified longer ago than this, like 24h, as a stale download")
+	flag.StringVar(&opts.stdinFormat, "stdin-format", "csv", "format of the - input, which has no file name to infer it from: csv or zip")
+	flag.StringVar(&opts.informat, "informat", "bank", "format of the inputs: bank for bank statements, bankcsv for the csv output of bankcsv, or json for the json exports of aggregators like plaid")
+	jsonFields := flag.String("json-fields", "", "keys of the fields of the -informat json transactions, like description=name,account=account_id, from "+strings.Join(jsonInputFieldNames, ","))
+	flag.BoolVar(&opts.jsonFields.deposits, "json-deposits-positive", false, "the positive amounts of the -informat json inputs are deposits, as in teller, instead of withdrawals, as in plaid")
+	bankName := flag.String("bank", "", "layout of the inputs, see -list-banks (default detected from the headers)")
+	listBanksFlag := flag.Bool("list-banks", false, "list the built-in bank layouts and exit")
+	flag.BoolVar(&opts.normalizeUnicode, "normalize-unicode", true, "normalize the descriptions to unicode NFC before matching")
+	flag.IntVar(&opts.maxDescLen, "max-desc-len", 0, "truncate the descriptions in the output to this many characters, 0 for no limit")
+	flag.StringVar(&opts.currency, "currency", "", "ISO 4217 currency of the transactions without one in the input")
+	flag.StringVar(&opts.baseCurrency, "base-currency", "", "ISO 4217 currency to convert the transactions to, with the -rates or -fx-api exchange rates")
+	flag.StringVar(&opts.ratesName, "rates", "", "json file with the units of each currency worth one unit of the -base-currency, also the fallback of -fx-api")
+	flag.StringVar(&opts.fxAPI, "fx-api", "", "url of a json api with the exchange rates of the -base-currency, that replaces {base}")
+	flag.DurationVar(&opts.fxTimeout, "fx-timeout", 30*time.Second, "timeout to fetch the -fx-api exchange rates")
+	flag.BoolVar(&opts.strict, "strict", false, "fail on invalid currencies and on the transactions that no rule or default account matched instead of warning")
+	dedupFlag := flag.Bool("dedup", false, "skip the transactions whose -dedup-by fields are the same as the ones of a previous transaction")
+	dedupBy := flag.String("dedup-by", "", "comma-separated fields that identify duplicates, from "+strings.Join(dedupFields, ",")+" (default date,description,value, implies -dedup)")
+	dedupScope := flag.String("dedup-scope", "run", "which transactions -dedup compares: run, all of them, or inputs, only the ones of different inputs, keeping the identical ones of an input (inputs implies -dedup)")
+	dedupKeep := flag.String("dedup-keep", "first", "which of the duplicates -dedup keeps: first, or last, which buffers all the transactions")
+	dedupWindow := flag.String("dedup-window", "", "only skip the duplicates of the transactions within this many days, like 7d, or this go duration, comparing their dates instead of requiring the same date (implies -dedup)")
+	sortBy := flag.String("sort-by", "", "sort the output by these comma-separated keys, from date, value, account and description, prefixed by - for descending order")
+	flag.BoolVar(&opts.collapseNewlines, "collapse-newlines", true, "replace the line breaks in the descriptions by spaces")
+	stripNumbers := flag.Bool("strip-numbers", false, "remove the trailing reference numbers of the descriptions, matched by -strip-numbers-regex, before matching")
+	stripNumbersRegex := flag.String("strip-numbers-regex", `(?i)(\s+((REF|AUTH)\b[\s:#.]*)?\d+)+$`, "regex of the reference numbers removed by -strip-numbers")
+	flag.BoolVar(&opts.noTrim, "no-trim", false, "keep the surrounding whitespace and quotes of the descriptions")
+	flag.StringVar(&opts.openingBalance, "opening-balance", "", "balance of the source account before the first transaction, emitted as an opening entry in the ledger formats")
+	flag.StringVar(&opts.openingAccount, "opening-balance-account", "", "account that balances the opening entry (default \""+ledgerOpeningAccount+"\", or "+beancountOpeningAccount+" in beancount)")
+	noHeaderComment := flag.Bool("no-header-comment", false, "don't write the comment with the inputs, the generation time and the date range in the ledger formats")
+	flag.StringVar(&opts.roundingAccount, "rounding-account", "", "account of the postings that balance the entries of the ledger formats whose legs don't add up to zero")
+	flag.StringVar(&opts.reconcile, "reconcile", "", "fail if the net change of the source accounts, the deposits minus the withdrawals of the transactions read, isn't this amount")
+	flag.StringVar(&opts.tolerance, "tolerance", "0", "maximum difference between the net change and the -reconcile amount")
+	flag.BoolVar(&opts.verifyBalance, "verify-balance", false, "check the balances of the inputs against -opening-balance plus the values")
+	flag.BoolVar(&opts.allowEmpty, "allow-empty", false, "don't fail when no transaction of the inputs could be assigned")
+	flag.BoolVar(&opts.skipBadLines, "skip-bad-lines", false, "skip the input lines that can't be parsed instead of aborting")
+	flag.IntVar(&opts.maxErrors, "max-errors", 100, "abort after this many bad lines with -skip-bad-lines, 0 for no limit")
+	flag.StringVar(&opts.accountSeparator, "account-separator", ":", "separator of the components of account names")
+	flag.StringVar(&opts.idPrefix, "id-prefix", "", "prefix of the transaction ids, or auto to derive it from the source account")
+	flag.StringVar(&opts.accountPrefix, "account-prefix", "", "prefix prepended to the assigned accounts")
+	flag.IntVar(&opts.overrides.date, "date-col", -1, "column of the inputs, starting at 0, with the date, overriding the layout")
+	flag.IntVar(&opts.overrides.description, "desc-col", -1, "column of the inputs, starting at 0, with the description, overriding the layout")
+	flag.IntVar(&opts.overrides.amount, "amount-col", -1, "column of the inputs, starting at 0, with a single signed amount, overriding the debit and credit columns of the layout")
+	flag.StringVar(&opts.overrides.dateLayout, "dateformat", "", "go time layout of the dates of the inputs, like 02/01/06, overriding the layout")
+	flag.IntVar(&opts.centuryPivot, "century-pivot", 69, "two-digit years of -dateformat below this one are in the 2000s, and the others in the 1900s")
+	flag.StringVar(&opts.zeroFallback, "zero-fallback", "on", "with debit and credit columns, on reads the credit column when the debit is empty or zero, off only when it's empty")
+	flag.BoolVar(&opts.drcr, "drcr", false, "read the trailing DR and CR indicators of the amounts, as in 12.50 DR, as their signs")
+	flag.BoolVar(&opts.cents, "cents", false, "read the amounts and balances of the inputs as integer numbers of cents, as in 1250 for 12.50")
+	flag.IntVar(&opts.srcAccountColumn, "srcaccount-col", -1, "column of the inputs, starting at 0, with the source account of each line, that overrides the srcAccount argument")
+	flag.StringVar(&opts.srcAccountPrefix, "srcaccount-prefix", "", "prefix prepended to the source account")
+	flag.BoolVar(&opts.coalesce, "coalesce", false, "merge the consecutive transactions with the same date and description")
+	flag.BoolVar(&verbose, "v", false, "verbose diagnostics")
+	logFormat := flag.String("log-format", "text", "format of the diagnostics: text or json")
+	flag.BoolVar(&opts.count, "count", false, "print the number of transactions that the run would write, after all the filters, instead of writing them")
+	preview := flag.Int("preview", 0, "print the first n transactions of the inputs as a table, with their values and types, and exit, to check the layout of a new bank")
+	samplesName := flag.String("check-samples", "", "check that the rules assign the expected accounts to the description,account[,value] lines of this csv file, and exit")
+	explainDesc := flag.String("explain", "", "print the rules that match the given description and exit")
+	emitSchemaFlag := flag.Bool("emit-schema", false, "print the JSON Schema of the config and exit")
+	sampleConfigFlag := flag.Bool("sample-config", false, "print an example config with all the fields, documented in // comments, and exit")
+	selftestFlag := flag.Bool("selftest", false, "run a synthetic statement through the conversion and back, check its invariants and exit")
+	batchName := flag.String("batch", "", "run the jobs of this json manifest, each one with its SrcAccount, Config, Inputs, Output and Flags, and exit")
+	batchFailFast := flag.Bool("batch-fail-fast", false, "stop the -batch at the first job that fails")
+	flag.Parse()
+	if *batchName != "" {
+		if err := runBatch(*batchName, *batchFailFast); err != nil {
+			fmt.Fprintln(os.Stderr, err) // nolint: errcheck
+			os.Exit(1)
+		}
+		return
+	}
+	if *selftestFlag {
+		errs := selftest()
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, "selftest:", err) // nolint: errcheck
+		}
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+		fmt.Println("selftest: ok")
+		return
+	}
+	if *emitSchemaFlag {
+		if err := emitSchema(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *sampleConfigFlag {
+		if err := writeSampleConfig(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if !*listBanksFlag {
+		if err := applyConfigOutput(&opts, *explainDesc != "" || *samplesName != ""); err != nil {
+			fmt.Fprintln(os.Stderr, err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	switch opts.legs {
+	case "both", "src", "dst":
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -legs %q, must be both, src or dst\n", opts.legs) // nolint: errcheck
+		os.Exit(1)
+	}
+	switch opts.csvStyle {
+	case "withdrawal":
+	case "signed":
+		if opts.legs != "both" {
+			fmt.Fprintln(os.Stderr, "-csv-style signed can't be used with -legs") // nolint: errcheck
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -csv-style %q, must be withdrawal or signed\n", opts.csvStyle) // nolint: errcheck
+		os.Exit(1)
+	}
+	switch *logFormat {
+	case "text":
+	case "json":
+		logJSON = true
+		log.SetFlags(0)
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -log-format %q, must be text or json\n", *logFormat) // nolint: errcheck
+		os.Exit(1)
+	}
+	if *listBanksFlag {
+		if err := listBanks(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	var err error
+	if *bankName != "" {
+		opts.bank, err = bankPreset(*bankName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if opts.outDir != "" {
+		if opts.format == "auto" {
+			opts.format = "csv"
+		}
+		for _, format := range strings.Split(opts.format, ",") {
+			if _, ok := outputFormats[format]; !ok {
+				fmt.Fprintf(os.Stderr, "unknown output format %q, must be one of %s\n", format, outputFormatNames()) // nolint: errcheck
+				os.Exit(1)
+			}
+			opts.formats = append(opts.formats, format)
+		}
+		opts.format = opts.formats[0]
+	}
+	opts.format, err = outputFormatName(opts.format, opts.outputName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err) // nolint: errcheck
+		os.Exit(1)
+	}
+	if opts.diffName != "" && opts.format != "csv" {
+		fmt.Fprintln(os.Stderr, "-diff only compares csv outputs") // nolint: errcheck
+		os.Exit(1)
+	}
+	if opts.openingBalance != "" {
+		if _, err := parseAmount(opts.openingBalance); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -opening-balance:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	opts.headerComment = !*noHeaderComment
+	if opts.reconcile != "" {
+		if _, err := parseAmount(opts.reconcile); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -reconcile:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if _, err := parseAmount(opts.tolerance); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid -tolerance:", err) // nolint: errcheck
+		os.Exit(1)
+	}
+	if *fieldsList != "" {
+		opts.fields, err = parseFields(*fieldsList)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -fields:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+		for _, field := range opts.fields {
+			opts.keepSourceLine = opts.keepSourceLine || field == "source"
+		}
+	}
+	if opts.currency != "" {
+		opts.currency, err = normalizeCurrency(opts.currency)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -currency:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if opts.baseCurrency != "" {
+		opts.baseCurrency, err = normalizeCurrency(opts.baseCurrency)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -base-currency:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if (opts.baseCurrency != "") != (opts.ratesName != "" || opts.fxAPI != "") {
+		fmt.Fprintln(os.Stderr, "-base-currency requires -rates or -fx-api, and vice versa") // nolint: errcheck
+		os.Exit(1)
+	}
+	if *dedupBy != "" {
+		opts.dedupBy, err = parseDedupBy(*dedupBy)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -dedup-by:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	} else if *dedupFlag || *dedupWindow != "" || *dedupScope == "inputs" {
+		opts.dedupBy = []string{"date", "description", "value"}
+	}
+	switch *dedupScope {
+	case "run":
+	case "inputs":
+		opts.dedupInputs = true
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -dedup-scope %q, must be run or inputs\n", *dedupScope) // nolint: errcheck
+		os.Exit(1)
+	}
+	switch *dedupKeep {
+	case "first":
+	case "last":
+		opts.dedupKeepLast = true
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -dedup-keep %q, must be first or last\n", *dedupKeep) // nolint: errcheck
+		os.Exit(1)
+	}
+	if *dedupWindow != "" {
+		opts.dedupWindow, err = parseDedupWindow(*dedupWindow)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -dedup-window:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	switch *symbolPosition {
+	case "before":
+	case "after":
+		opts.symbol.after = true
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -symbol-position %q, must be before or after\n", *symbolPosition) // nolint: errcheck
+		os.Exit(1)
+	}
+	if *sortBy != "" {
+		opts.sortBy, err = parseSortBy(*sortBy)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -sort-by:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if *recordSep != "" {
+		if len(*recordSep) != 1 || *recordSep == "\"" {
+			fmt.Fprintf(os.Stderr, "Invalid -record-sep %q, must be a single ascii character other than a quote\n", *recordSep) // nolint: errcheck
+			os.Exit(1)
+		}
+		opts.recordSep = (*recordSep)[0]
+	}
+	if opts.stdinFormat != "csv" && opts.stdinFormat != "zip" {
+		fmt.Fprintf(os.Stderr, "Invalid -stdin-format %q, must be csv or zip\n", opts.stdinFormat) // nolint: errcheck
+		os.Exit(1)
+	}
+	if opts.informat != "bank" && opts.informat != "bankcsv" && opts.informat != "json" {
+		fmt.Fprintf(os.Stderr, "Invalid -informat %q, must be bank, bankcsv or json\n", opts.informat) // nolint: errcheck
+		os.Exit(1)
+	}
+	opts.jsonFields.keys = defaultJSONInputFields()
+	if *jsonFields != "" {
+		if err := opts.jsonFields.parse(*jsonFields); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -json-fields:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if opts.quote != "minimal" && opts.quote != "all" {
+		fmt.Fprintf(os.Stderr, "Invalid -quote %q, must be minimal or all\n", opts.quote) // nolint: errcheck
+		os.Exit(1)
+	}
+	opts.numbers, err = localeNumberFormat(opts.locale)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err) // nolint: errcheck
+		os.Exit(1)
+	}
+	opts.months, err = localeMonthNames(opts.dateLocale)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err) // nolint: errcheck
+		os.Exit(1)
+	}
+	opts.location, err = time.LoadLocation(*tz)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid -tz:", err) // nolint: errcheck
+		os.Exit(1)
+	}
+	if *outputTZ != "" {
+		opts.outputLocation, err = time.LoadLocation(*outputTZ)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -output-tz:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if opts.zeroFallback != "on" && opts.zeroFallback != "off" {
+		fmt.Fprintf(os.Stderr, "Invalid -zero-fallback %q, must be on or off\n", opts.zeroFallback) // nolint: errcheck
+		os.Exit(1)
+	}
+	if opts.idScope != "run" && opts.idScope != "file" {
+		fmt.Fprintf(os.Stderr, "Invalid -id-scope %q, must be run or file\n", opts.idScope) // nolint: errcheck
+		os.Exit(1)
+	}
+	if opts.only != "" && opts.only != "debits" && opts.only != "credits" {
+		fmt.Fprintf(os.Stderr, "Invalid -only %q, must be debits or credits\n", opts.only) // nolint: errcheck
+		os.Exit(1)
+	}
+	if *filterFlag != "" {
+		opts.filter, err = parseFilter(*filterFlag, opts.dateLocation())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -filter:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if *stripNumbers {
+		opts.stripNumbers, err = regexp.Compile(*stripNumbersRegex)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -strip-numbers-regex:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if *dateFromFilename != "" {
+		opts.fileDates, err = newFileDates(*dateFromFilename, opts.dateLocation())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -date-from-filename:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if *sinceDate != "" {
+		opts.sinceDate, err = time.ParseInLocation("2006-01-02", *sinceDate, opts.dateLocation())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid -since-date:", err) // nolint: errcheck
+			os.Exit(1)
+		}
+	}
+	if opts.configDir != "" && opts.configEnv != "" {
+		fmt.Fprintln(os.Stderr, "-config-dir and -config-env can't be used together") // nolint: errcheck
+		os.Exit(1)
+	}
+	if *samplesName != "" {
+		jsonName := new(string)
+		if opts.configDir == "" && opts.configEnv == "" {
+			if flag.NArg() != 1 {
+				fmt.Fprintf(os.Stderr, "Usage: bankcsv -check-samples <samples csv> <json config file>\n") // nolint: errcheck
+				os.Exit(1)
+			}
+			jsonName = &flag.Args()[0]
+		}
+		cfg, err := loadConfig(jsonName, &opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		failed, err := checkSamples(os.Stdout, &cfg, &opts, *samplesName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if *explainDesc != "" {
+		jsonName := new(string)
+		if opts.configDir == "" && opts.configEnv == "" {
+			if flag.NArg() != 1 {
+				fmt.Fprintf(os.Stderr, "Usage: bankcsv -explain <description> <json config file>\n") // nolint: errcheck
+				os.Exit(1)
+			}
+			jsonName = &flag.Args()[0]
+		}
+		cfg, err := loadConfig(jsonName, &opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := explain(os.Stdout, &cfg, newDescriptionCleaner(&opts).clean(*explainDesc)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *preview > 0 {
+		if flag.NArg() == 0 {
+			fmt.Fprintf(os.Stderr, "Usage: bankcsv -preview <n> <inputs...>\n") // nolint: errcheck
+			os.Exit(1)
+		}
+		if err := previewTransactions(os.Stdout, flag.Args(), *preview, &opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	args := flag.Args()
+	srcAccount := new(string)
+	if len(args) > 0 && !isFileArg(args[0]) {
+		srcAccount = &args[0]
+		args = args[1:]
+	}
+	numArgs := 2
+	if opts.configDir != "" || opts.configEnv != "" {
+		numArgs = 1
+	}
+	if len(args) < numArgs {
+		fmt.Fprintf(os.Stderr, "Wrong number of arguments\n")                                             // nolint: errcheck
+		fmt.Fprintf(os.Stderr, "Usage: bankcsv [srcAccount] <json config file> <inputs...>\n")            // nolint: errcheck
+		fmt.Fprintf(os.Stderr, "       bankcsv -config-dir <json config dir> [srcAccount] <inputs...>\n") // nolint: errcheck
+		fmt.Fprintf(os.Stderr, "       bankcsv -config-env <variable> [srcAccount] <inputs...>\n")        // nolint: errcheck
+		flag.PrintDefaults()
+		exitError := 1
+		os.Exit(exitError)
+	}
+	jsonName := new(string)
+	inputNames := args
+	if numArgs == 2 {
+		jsonName = &args[0]
+		inputNames = args[1:]
+	}
+	opts.inputNames = inputNames
+	if err := processCsvs(srcAccount, jsonName, &opts, inputNames); err != nil {
+		fmt.Fprintln(os.Stderr, err) // nolint: errcheck
+		os.Exit(1)
 	}
-	args := flag.Args()
-	srcAccount := &args[0]
-	jsonName := &args[1]
-	inputNames := args[2:]
-	processCsvs(srcAccount, jsonName, outputName, inputNames)
 }
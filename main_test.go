@@ -0,0 +1,589 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCsvDestinationLegs(t *testing.T) {
+	const header = "id,date,description,withdrawal,account\n"
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"12.50", header + "1,2018-03-01,TESCO,12.50,Assets:Checking\n,,,-12.50,Expenses:Groceries\n"},
+		{"+12.50", header + "1,2018-03-01,TESCO,+12.50,Assets:Checking\n,,,-12.50,Expenses:Groceries\n"},
+		{"-0.00", header + "1,2018-03-01,TESCO,-0.00,Assets:Checking\n,,,0.00,Expenses:Groceries\n"},
+		{"", header + "1,2018-03-01,TESCO,,Assets:Checking\n,,,,Expenses:Groceries\n"},
+	}
+	for _, test := range tests {
+		opts := options{outputDateFormat: "2006-01-02", legs: "both"}
+		o := newOutputCsvFormat(&opts, &config{}, "Assets:Checking")
+		var out bytes.Buffer
+		if err := o.Init(&out); err != nil {
+			t.Fatal(err)
+		}
+		tr := transaction{ID: "1", Date: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), Description: "TESCO",
+			Value: test.value, SrcAccount: "Assets:Checking", Account: "Expenses:Groceries"}
+		if err := o.Add(&tr); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Finish(); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.want {
+			t.Errorf("value %q: got\n%s\nwant\n%s", test.value, out.String(), test.want)
+		}
+	}
+}
+
+func TestIsFileArg(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"statements.2024", "cfg"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "dir.csv"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		arg  string
+		want bool
+	}{
+		{filepath.Join(dir, "statements.2024"), true},
+		{filepath.Join(dir, "cfg"), true},
+		{filepath.Join(dir, "dir.csv"), false},
+		{filepath.Join(dir, "dir.csv") + "/", false},
+		{filepath.Join(dir, "missing.json"), false},
+		{"Assets:Checking", false},
+		{"https://example.com/config.json", true},
+	}
+	for _, test := range tests {
+		if got := isFileArg(test.arg); got != test.want {
+			t.Errorf("isFileArg(%q) = %v, want %v", test.arg, got, test.want)
+		}
+	}
+}
+
+const (
+	aibDebitHeader  = "Posted Account, Posted Transactions Date, Description1, Description2, Description3, Debit Amount, Credit Amount, Balance, Posted Currency, Transaction Type\n"
+	aibCreditHeader = "Masked Card Number, Posted Transactions Date, Description, Debit Amount, Credit Amount, Posted Currency, Transaction Type\n"
+)
+
+// parserOptions returns the options of the parser with the defaults of the
+// flags.
+func parserOptions() *options {
+	return &options{informat: "bank", zeroFallback: "on", idScope: "run", centuryPivot: 69, srcAccountColumn: -1,
+		overrides: layoutOverrides{date: -1, description: -1, amount: -1}, location: time.UTC, maxErrors: 100}
+}
+
+// parseAll parses the input, detecting its layouts, failing the test on
+// errors.
+func parseAll(t *testing.T, input string, opts *options) []*transaction {
+	t.Helper()
+	var transactions []*transaction
+	for r := range parseReader(strings.NewReader(input), nil, opts) {
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		transactions = append(transactions, r.t)
+	}
+	return transactions
+}
+
+func TestParseConcatenatedStatements(t *testing.T) {
+	input := aibDebitHeader +
+		`"123-456",01/03/2018,"TESCO STORES 123","","",12.50,,987.50,EUR,Debit` + "\n" +
+		// The header of the second file, with its byte order mark.
+		"\ufeff" + aibDebitHeader +
+		`"123-456",02/03/2018,"SALARY ACME","","",,1000.00,1987.50,EUR,Credit` + "\n" +
+		aibDebitHeader
+	transactions := parseAll(t, input, parserOptions())
+	if len(transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(transactions))
+	}
+	want := []struct {
+		description, value, typ string
+		line                    int
+	}{
+		{"TESCO STORES 123", "12.50", "debit", 2},
+		{"SALARY ACME", "-1000.00", "credit", 4},
+	}
+	for i, w := range want {
+		tr := transactions[i]
+		if tr.Description != w.description || tr.Value != w.value || tr.Type != w.typ || tr.Line != w.line {
+			t.Errorf("transaction %d: got %s %s %s at line %d, want %s %s %s at line %d",
+				i, tr.Description, tr.Value, tr.Type, tr.Line, w.description, w.value, w.typ, w.line)
+		}
+	}
+}
+
+func TestStdinContinuesIDCounters(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "debit.csv")
+	stdin := filepath.Join(dir, "stdin.csv")
+	line := `"123-456",01/03/2018,"TESCO STORES 123","","",12.50,,987.50,EUR,Debit` + "\n"
+	for _, name := range []string{file, stdin} {
+		if err := ioutil.WriteFile(name, []byte(aibDebitHeader+line), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fd, err := os.Open(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close() // nolint: errcheck
+	saved := os.Stdin
+	os.Stdin = fd
+	defer func() { os.Stdin = saved }()
+	var errs pipelineError
+	var ids []string
+	for tr := range inputsParse([]string{file, "-"}, parserOptions(), &errs) {
+		ids = append(ids, tr.File+" "+tr.ID)
+	}
+	if err := errs.get(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{file + " 2018030101", "- 2018030102"}
+	if len(ids) != len(want) || ids[0] != want[0] || ids[1] != want[1] {
+		t.Errorf("got ids %q, want %q", ids, want)
+	}
+}
+
+// BenchmarkParseReader parses a large synthetic statement from memory.
+func BenchmarkParseReader(b *testing.B) {
+	var statement strings.Builder
+	statement.WriteString(aibDebitHeader)
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&statement, "\"123-456\",%02d/03/2018,\"TESCO STORES %d\",\"\",\"\",%d.50,,987.50,EUR,Debit\n", i%28+1, i, i%100)
+	}
+	input := statement.String()
+	opts := parserOptions()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for r := range parseReader(strings.NewReader(input), nil, opts) {
+			if r.err != nil {
+				b.Fatal(r.err)
+			}
+		}
+	}
+}
+
+func TestParseMixedLayouts(t *testing.T) {
+	input := aibCreditHeader +
+		`"XXXX-1234",05/03/2018,"AMAZON MKTPLACE",25.00,,EUR,Debit` + "\n" +
+		`"XXXX-1234",06/03/2018,"PAYMENT THANK YOU",,100.00,EUR,Credit` + "\n" +
+		aibDebitHeader +
+		`"123-456",07/03/2018,"TESCO STORES 123","","",12.50,,987.50,EUR,Debit` + "\n" +
+		aibCreditHeader +
+		`"XXXX-1234",08/03/2018,"REFUND",,5.00,USD,Credit` + "\n"
+	transactions := parseAll(t, input, parserOptions())
+	want := []struct {
+		date, description, value, balance, currency string
+	}{
+		{"2018-03-05", "AMAZON MKTPLACE", "25.00", "", "EUR"},
+		{"2018-03-06", "PAYMENT THANK YOU", "-100.00", "", "EUR"},
+		{"2018-03-07", "TESCO STORES 123", "12.50", "987.50", "EUR"},
+		{"2018-03-08", "REFUND", "-5.00", "", "USD"},
+	}
+	if len(transactions) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(transactions), len(want))
+	}
+	for i, w := range want {
+		tr := transactions[i]
+		got := []string{tr.Date.Format("2006-01-02"), tr.Description, tr.Value, tr.Balance, tr.Currency}
+		expected := []string{w.date, w.description, w.value, w.balance, w.currency}
+		for j := range got {
+			if got[j] != expected[j] {
+				t.Errorf("transaction %d: got %q, want %q", i, got, expected)
+				break
+			}
+		}
+	}
+}
+
+// TestMain runs main instead of the tests in the subprocesses of runMain.
+func TestMain(m *testing.M) {
+	if os.Getenv("BANKCSV_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs bankcsv with the arguments in a subprocess, returning its
+// standard output and error separately.
+func runMain(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "BANKCSV_TEST_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestValueParseZeroDebit(t *testing.T) {
+	layout, err := bankPreset("aib-debit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newInputParser(nil, parserOptions())
+	tests := []struct {
+		debit, credit string
+		value, typ    string
+	}{
+		{"12.50", "", "12.50", "debit"},
+		{"", "100.00", "-100.00", "credit"},
+		// The informational rows of some banks, with a zero in a column.
+		{"0.00", "100.00", "-100.00", "credit"},
+		{"0.00", "", "0.00", "debit"},
+		{"0.00", "0.00", "0.00", "credit"},
+		{"", "", "", "credit"},
+	}
+	for _, test := range tests {
+		line := []string{"123-456", "01/03/2018", "TESCO", "", "", test.debit, test.credit, "", "EUR", "Debit"}
+		value, typ, err := p.valueParse(line, layout)
+		if err != nil {
+			t.Errorf("debit %q credit %q: %v", test.debit, test.credit, err)
+			continue
+		}
+		if value != test.value || typ != test.typ {
+			t.Errorf("debit %q credit %q: got %q %s, want %q %s", test.debit, test.credit, value, typ, test.value, test.typ)
+		}
+	}
+}
+
+func TestValueParseZeroFallbackOff(t *testing.T) {
+	layout, err := bankPreset("aib-debit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := parserOptions()
+	opts.zeroFallback = "off"
+	p := newInputParser(nil, opts)
+	tests := []struct {
+		debit, credit string
+		value, typ    string
+	}{
+		{"", "100.00", "-100.00", "credit"},
+		{"", "", "", "credit"},
+		// The zero debits are real debits, the credit is not read.
+		{"0.00", "100.00", "0.00", "debit"},
+		{"0.00", "", "0.00", "debit"},
+		{"12.50", "100.00", "12.50", "debit"},
+	}
+	for _, test := range tests {
+		line := []string{"123-456", "01/03/2018", "TESCO", "", "", test.debit, test.credit, "", "EUR", "Debit"}
+		value, typ, err := p.valueParse(line, layout)
+		if err != nil {
+			t.Errorf("debit %q credit %q: %v", test.debit, test.credit, err)
+			continue
+		}
+		if value != test.value || typ != test.typ {
+			t.Errorf("debit %q credit %q: got %q %s, want %q %s", test.debit, test.credit, value, typ, test.value, test.typ)
+		}
+	}
+}
+
+func TestDropZero(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.json")
+	input := filepath.Join(dir, "debit.csv")
+	stats := filepath.Join(dir, "stats.json")
+	if err := ioutil.WriteFile(config, []byte(`{"AccountFromDescription": [{"Account": "Expenses:Groceries", "Regex": "TESCO"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	statement := aibDebitHeader +
+		`"123-456",01/03/2018,"TESCO STORES","","",12.50,,987.50,EUR,Debit` + "\n" +
+		`"123-456",01/03/2018,"TESCO AUTHORIZATION","","",0.00,,987.50,EUR,Debit` + "\n" +
+		`"123-456",02/03/2018,"TESCO REVERSAL","","",0.00,0.00,987.50,EUR,Debit` + "\n"
+	if err := ioutil.WriteFile(input, []byte(statement), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, err := runMain(t, "-drop-zero", "-v", "-stats-out", stats, "-o", "-", "Assets:Checking", config, input)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := "id,date,description,withdrawal,account\n" +
+		"2018030101,2018-03-01,TESCO STORES,12.50,Assets:Checking\n" +
+		",,,-12.50,Expenses:Groceries\n"
+	if stdout != want {
+		t.Errorf("got\n%s\nwant\n%s", stdout, want)
+	}
+	if !strings.Contains(stderr, "dropped 2 zero-value transactions") {
+		t.Errorf("no count of the dropped transactions in the diagnostics:\n%s", stderr)
+	}
+	dat, err := ioutil.ReadFile(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dat), `"zero": 2`) {
+		t.Errorf("no zero count in the stats:\n%s", dat)
+	}
+}
+
+func TestDatesInTimeZone(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.json")
+	input := filepath.Join(dir, "debit.csv")
+	if err := ioutil.WriteFile(config, []byte(`{"AccountFromDescription": [{"Account": "Expenses:Groceries", "Regex": "TESCO", "After": "2018-03-02"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	statement := aibDebitHeader +
+		`"123-456",01/03/2018,"TESCO","","",12.50,,,EUR,Debit` + "\n" +
+		`"123-456",02/03/2018,"TESCO","","",13.50,,,EUR,Debit` + "\n"
+	if err := ioutil.WriteFile(input, []byte(statement), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		// The dates of the day of -since-date are not after it, even
+		// behind UTC.
+		{[]string{"-tz", "America/New_York", "-since-date", "2018-03-01"},
+			"2018030201,2018-03-02,TESCO,13.50,Assets:Checking\n,,,-13.50,Expenses:Groceries\n"},
+		// The dates of -filter are in -tz too.
+		{[]string{"-tz", "America/New_York", "-filter", "date == 2018-03-02"},
+			"2018030201,2018-03-02,TESCO,13.50,Assets:Checking\n,,,-13.50,Expenses:Groceries\n"},
+		{[]string{"-tz", "Pacific/Auckland", "-filter", "date >= 2018-03-02"},
+			"2018030201,2018-03-02,TESCO,13.50,Assets:Checking\n,,,-13.50,Expenses:Groceries\n"},
+		// The dates of the day of After are on it, even ahead of UTC.
+		{[]string{"-tz", "Pacific/Auckland"},
+			"2018030201,2018-03-02,TESCO,13.50,Assets:Checking\n,,,-13.50,Expenses:Groceries\n"},
+	}
+	for _, test := range tests {
+		args := append(test.args, "-o", "-", "Assets:Checking", config, input)
+		stdout, stderr, err := runMain(t, args...)
+		if err != nil {
+			t.Fatalf("%q: %v: %s", test.args, err, stderr)
+		}
+		if want := "id,date,description,withdrawal,account\n" + test.want; stdout != want {
+			t.Errorf("%q: got\n%s\nwant\n%s", test.args, stdout, want)
+		}
+	}
+}
+
+func TestNextID(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2018, 3, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name  string
+		dates []time.Time
+		want  []string
+	}{
+		{"first row", []time.Time{day(1)}, []string{"2018030101"}},
+		{"one day", []time.Time{day(1), day(1), day(1)}, []string{"2018030101", "2018030102", "2018030103"}},
+		{"interleaved dates", []time.Time{day(1), day(2), day(1), day(3), day(2)},
+			[]string{"2018030101", "2018030201", "2018030102", "2018030301", "2018030202"}},
+		{"times of the day", []time.Time{day(1).Add(9 * time.Hour), day(1).Add(8 * time.Hour)},
+			[]string{"2018030101", "2018030102"}},
+	}
+	for _, test := range tests {
+		p := newInputParser(nil, parserOptions())
+		p.fileCounters = map[string]int{}
+		for i, date := range test.dates {
+			id, fileID := p.nextID(date)
+			if id != test.want[i] || fileID != test.want[i] {
+				t.Errorf("%s: row %d got %s and %s, want %s", test.name, i+1, id, fileID, test.want[i])
+			}
+		}
+	}
+}
+
+func TestNextIDPast99(t *testing.T) {
+	p := newInputParser(nil, parserOptions())
+	p.fileCounters = map[string]int{}
+	seen := map[string]bool{}
+	var ids []string
+	for i := 0; i < 120; i++ {
+		id, _ := p.nextID(time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC))
+		if seen[id] {
+			t.Fatalf("repeated id %s", id)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	// The counter widens to three digits after the 99th transaction.
+	for i, want := range map[int]string{0: "2018030101", 98: "2018030199", 99: "20180301100", 119: "20180301120"} {
+		if ids[i] != want {
+			t.Errorf("transaction %d: got %s, want %s", i+1, ids[i], want)
+		}
+	}
+}
+
+func TestIDCountersAcrossInputs(t *testing.T) {
+	names := writeStatements(t, []string{
+		`"1",01/03/2018,"TESCO","","",12.50,,,EUR,Debit`,
+		`"1",02/03/2018,"COFFEE","","",3.00,,,EUR,Debit`,
+	}, []string{
+		`"1",02/03/2018,"COFFEE","","",3.00,,,EUR,Debit`,
+		`"1",01/03/2018,"TESCO","","",12.50,,,EUR,Debit`,
+	}, []string{
+		`"1",02/03/2018,"AMAZON","","",20.00,,,EUR,Debit`,
+	})
+	tests := []struct {
+		scope string
+		want  []string
+	}{
+		{"run", []string{"2018030101", "2018030201", "2018030202", "2018030102", "2018030203"}},
+		{"file", []string{"2018030101", "2018030201", "2018030201", "2018030101", "2018030201"}},
+	}
+	for _, test := range tests {
+		opts := parserOptions()
+		opts.idScope = test.scope
+		var errs pipelineError
+		var ids, fileIDs []string
+		for tr := range inputsParse(names, opts, &errs) {
+			ids = append(ids, tr.ID)
+			fileIDs = append(fileIDs, tr.fileID)
+		}
+		if err := errs.get(); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(ids, ",") != strings.Join(test.want, ",") {
+			t.Errorf("-id-scope %s: got %q, want %q", test.scope, ids, test.want)
+		}
+		// The ids of the inputs alone don't depend on the scope.
+		if want := []string{"2018030101", "2018030201", "2018030201", "2018030101", "2018030201"}; strings.Join(fileIDs, ",") != strings.Join(want, ",") {
+			t.Errorf("-id-scope %s: got file ids %q, want %q", test.scope, fileIDs, want)
+		}
+	}
+}
+
+func TestDiagnosticsToStderr(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.json")
+	input := filepath.Join(dir, "debit.csv")
+	if err := ioutil.WriteFile(config, []byte(`{"AccountFromDescription": [{"Account": "Expenses:Groceries", "Regex": "TESCO"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	statement := aibDebitHeader +
+		`"123-456",01/03/2018,"TESCO STORES","","",12.50,,987.50,EUR,Debit` + "\n" +
+		`"123-456",02/03/2018,"UNKNOWN SHOP","","",5.00,,982.50,EUR,Debit` + "\n"
+	if err := ioutil.WriteFile(input, []byte(statement), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, err := runMain(t, "-v", "-o", "-", "Assets:Checking", config, input)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := "id,date,description,withdrawal,account\n" +
+		"2018030101,2018-03-01,TESCO STORES,12.50,Assets:Checking\n" +
+		",,,-12.50,Expenses:Groceries\n"
+	if stdout != want {
+		t.Errorf("got the output\n%s\nwant\n%s", stdout, want)
+	}
+	if !strings.Contains(stderr, "could not assign account to UNKNOWN SHOP") {
+		t.Errorf("no warning of the unassigned transaction in the diagnostics:\n%s", stderr)
+	}
+	if strings.Contains(stderr, "TESCO STORES,12.50") {
+		t.Errorf("the output is in the diagnostics:\n%s", stderr)
+	}
+}
+
+func TestReplayInTimeZone(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.json")
+	input := filepath.Join(dir, "bankcsv.csv")
+	rules := `{"AccountFromDescription": [
+		{"Account": "Expenses:New", "Regex": "TESCO", "After": "2018-03-02"},
+		{"Account": "Expenses:Old", "Regex": "TESCO"}]}`
+	if err := ioutil.WriteFile(config, []byte(rules), 0600); err != nil {
+		t.Fatal(err)
+	}
+	output := "id,date,description,withdrawal,account\n" +
+		"2018030101,2018-03-01,TESCO,12.50,Assets:Checking\n,,,-12.50,Expenses:Groceries\n" +
+		"2018030201,2018-03-02,TESCO,13.50,Assets:Checking\n,,,-13.50,Expenses:Groceries\n"
+	if err := ioutil.WriteFile(input, []byte(output), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, err := runMain(t, "-informat", "bankcsv", "-tz", "America/New_York", "-o", "-", "Assets:Checking", config, input)
+	if err != nil {
+		t.Fatalf("%v: %s", err, stderr)
+	}
+	want := "id,date,description,withdrawal,account\n" +
+		"2018030101,2018-03-01,TESCO,12.50,Assets:Checking\n,,,-12.50,Expenses:Old\n" +
+		"2018030201,2018-03-02,TESCO,13.50,Assets:Checking\n,,,-13.50,Expenses:New\n"
+	if stdout != want {
+		t.Errorf("got\n%s\nwant\n%s", stdout, want)
+	}
+}
+
+func TestStatsOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.json")
+	input := filepath.Join(dir, "debit.csv")
+	stats := filepath.Join(dir, "stats.json")
+	if err := ioutil.WriteFile(config, []byte(`{"AccountFromDescription": [{"Account": "Expenses:Groceries", "Regex": "TESCO"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	statement := aibDebitHeader +
+		`"123-456",01/03/2018,"TESCO STORES","","",12.50,,987.50,EUR,Debit` + "\n" +
+		`"123-456",99/03/2018,"TESCO STORES","","",13.50,,974.00,EUR,Debit` + "\n"
+	if err := ioutil.WriteFile(input, []byte(statement), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, err := runMain(t, "-stats-out", stats, "-o", filepath.Join(dir, "out.csv"), "Assets:Checking", config, input)
+	if err == nil {
+		t.Fatalf("no error with an invalid date: %s", stderr)
+	}
+	dat, err := ioutil.ReadFile(stats)
+	if err != nil {
+		t.Fatalf("no stats after the failure: %v", err)
+	}
+	for _, want := range []string{`"total": 1`, `"matched": 1`} {
+		if !strings.Contains(string(dat), want) {
+			t.Errorf("no %s in the stats:\n%s", want, dat)
+		}
+	}
+}
+
+func TestOnlyKeepsZero(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.json")
+	input := filepath.Join(dir, "debit.csv")
+	if err := ioutil.WriteFile(config, []byte(`{"AccountFromDescription": [{"Account": "Expenses:Groceries", "Regex": "TESCO"}, {"Account": "Income:Salary", "Regex": "SALARY"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	statement := aibDebitHeader +
+		`"123-456",01/03/2018,"TESCO STORES","","",12.50,,987.50,EUR,Debit` + "\n" +
+		`"123-456",01/03/2018,"TESCO AUTHORIZATION","","",0.00,,987.50,EUR,Debit` + "\n" +
+		`"123-456",02/03/2018,"SALARY","","",,1000.00,1987.50,EUR,Credit` + "\n"
+	if err := ioutil.WriteFile(input, []byte(statement), 0600); err != nil {
+		t.Fatal(err)
+	}
+	const zero = "2018030102,2018-03-01,TESCO AUTHORIZATION,0.00,Assets:Checking\n,,,0.00,Expenses:Groceries\n"
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-only", "debits"}, "2018030101,2018-03-01,TESCO STORES,12.50,Assets:Checking\n,,,-12.50,Expenses:Groceries\n" + zero},
+		{[]string{"-only", "credits"}, zero + "2018030201,2018-03-02,SALARY,-1000.00,Assets:Checking\n,,,1000.00,Income:Salary\n"},
+		{[]string{"-only", "credits", "-drop-zero"}, "2018030201,2018-03-02,SALARY,-1000.00,Assets:Checking\n,,,1000.00,Income:Salary\n"},
+	}
+	for _, test := range tests {
+		args := append(test.args, "-o", "-", "Assets:Checking", config, input)
+		stdout, stderr, err := runMain(t, args...)
+		if err != nil {
+			t.Fatalf("%q: %v: %s", test.args, err, stderr)
+		}
+		if want := "id,date,description,withdrawal,account\n" + test.want; stdout != want {
+			t.Errorf("%q: got\n%s\nwant\n%s", test.args, stdout, want)
+		}
+	}
+}
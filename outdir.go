@@ -0,0 +1,93 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Output directory:
+
+// outputBaseName is the name of the files written by -out-dir, without the
+// extension.
+const outputBaseName = "transactions"
+
+// outputFormatExtension returns the extension of the files of the format.
+func outputFormatExtension(format string) string {
+	if format == "ynab" {
+		return ".ynab.csv"
+	}
+	for ext, name := range outputExtensions {
+		if name == format {
+			return ext
+		}
+	}
+	return ".txt"
+}
+
+// outputDirFormat writes the transactions in several formats at once, each
+// one to its file in the -out-dir directory, like transactions.csv.
+type outputDirFormat struct {
+	outDir  string
+	gzip    bool
+	names   []string // of the files of the formats
+	formats []outputFormat
+	files   []*outputFile
+}
+
+func newOutputDirFormat(opts *options, cfg *config, srcAccount string) outputFormat {
+	o := outputDirFormat{outDir: opts.outDir}
+	for _, format := range opts.formats {
+		name := filepath.Join(opts.outDir, outputBaseName+outputFormatExtension(format))
+		if opts.gzip {
+			name += ".gz"
+		}
+		o.names = append(o.names, name)
+		o.formats = append(o.formats, outputFormats[format](opts, cfg, srcAccount))
+	}
+	o.gzip = opts.gzip
+	return &o
+}
+
+// Init creates the directory and opens the files, ignoring out.
+func (o *outputDirFormat) Init(out io.Writer) error {
+	if err := os.MkdirAll(o.outDir, 0750); err != nil {
+		return err
+	}
+	for i, format := range o.formats {
+		file, err := openOutput(o.names[i], o.gzip)
+		if err != nil {
+			return err
+		}
+		o.files = append(o.files, file)
+		if err := format.Init(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *outputDirFormat) Add(t *transaction) error {
+	for _, format := range o.formats {
+		if err := format.Add(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *outputDirFormat) Finish() error {
+	for i, format := range o.formats {
+		if err := format.Finish(); err != nil {
+			return err
+		}
+		if err := o.files[i].close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
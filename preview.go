@@ -0,0 +1,40 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"io"
+)
+
+// Preview:
+
+// previewTransactions prints the first n transactions of the inputs as a
+// table, with their dates, descriptions, values and types, to check the
+// layout of a new bank before writing any rules.
+func previewTransactions(out io.Writer, inputNames []string, n int, opts *options) error {
+	w := &tableWriter{out: out}
+	if err := w.Write([]string{"date", "description", "withdrawal", "type"}); err != nil {
+		return err
+	}
+	count := 0
+	var errs pipelineError
+	for t := range inputsParse(inputNames, opts, &errs) {
+		if count == n {
+			break
+		}
+		if err := w.Write([]string{t.Date.Format(opts.outputDateFormat), t.Description, t.Value, t.Type}); err != nil {
+			return err
+		}
+		count++
+	}
+	if count < n {
+		// The errors after the first n transactions don't matter.
+		if err := errs.get(); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
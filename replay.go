@@ -0,0 +1,125 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Replay:
+
+// parseBankcsv parses the csv output of bankcsv, with -informat bankcsv, so
+// that the rules can be applied again. Only the transactions are read back;
+// the accounts and balancing rows are ignored, and the signed rows of split
+// transactions are summed. The rows of the withdrawals must be the ones of
+// the source account, which processCsvs checks with the account of the
+// row, as the ones of -legs dst have the opposite signs.
+func (p *inputParser) parseBankcsv(inputName string, input io.Reader) error {
+	inputCsv := csv.NewReader(bufio.NewReader(input))
+	inputCsv.FieldsPerRecord = -1
+	header, err := inputCsv.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return p.badLine(inputName, 1, err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[headerCell(name)] = i
+	}
+	valueName, signed := "withdrawal", false
+	if _, ok := cols["amount"]; ok {
+		valueName, signed = "amount", true
+	}
+	for _, name := range []string{"id", "date", "description", valueName} {
+		if _, ok := cols[name]; !ok {
+			return p.badLine(inputName, 1, fmt.Errorf("header without the %s column of the bankcsv format", name))
+		}
+	}
+	var pending *transaction
+	var sum amount
+	flush := func() {
+		if pending == nil {
+			return
+		}
+		if signed {
+			pending.Value = sum.Neg().String()
+		}
+		if pending.Type == "" {
+			pending.Type = valueSign(pending)
+		}
+		p.out <- parseResult{t: pending}
+		pending = nil
+	}
+	for lineNum := 2; ; lineNum++ {
+		line, err := inputCsv.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			if err := p.badLine(inputName, lineNum, err); err != nil {
+				return err
+			}
+			continue
+		}
+		get := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(line) {
+				return line[i]
+			}
+			return ""
+		}
+		id := get("id")
+		if id == "" {
+			continue
+		}
+		value := get(valueName)
+		var amt amount
+		if signed {
+			amt, err = parseAmount(value)
+			if err != nil {
+				if err := p.badLine(inputName, lineNum, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if pending != nil && pending.ID == id {
+				sum = sum.Add(amt)
+				continue
+			}
+		}
+		date, err := time.ParseInLocation(p.dateFormat, get("date"), p.location)
+		if err != nil {
+			if err := p.badLine(inputName, lineNum, err); err != nil {
+				return err
+			}
+			continue
+		}
+		rowAccount := ""
+		if !signed {
+			rowAccount = get("account")
+		}
+		flush()
+		sum = amt
+		pending = &transaction{
+			ID:          id,
+			Date:        date,
+			Description: get("description"),
+			Value:       value,
+			RawValue:    get("raw_value"),
+			Balance:     get("balance"),
+			Currency:    get("currency"),
+			Type:        get("type"),
+			Fields:      line,
+			rowAccount:  rowAccount,
+			File:        inputName,
+			Line:        lineNum,
+		}
+	}
+	flush()
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// loadRules loads the config files, the first one being the one given,
+// and returns the account assigned to each description.
+func loadRules(t *testing.T, files map[string]string, descriptions ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jsonName := filepath.Join(dir, "config.json")
+	cfg, err := loadConfig(&jsonName, &options{accountSeparator: ":"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var accounts []string
+	for _, description := range descriptions {
+		tr := transaction{Description: description, Value: "10.00"}
+		if _, err := cfg.assign(&tr); err != nil {
+			t.Fatal(err)
+		}
+		accounts = append(accounts, tr.Account)
+	}
+	return accounts
+}
+
+func TestRulesFirstMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{"first match", map[string]string{"config.json": `{"AccountFromDescription": [
+			{"Account": "Expenses:Fuel", "Regex": "TESCO PETROL"},
+			{"Account": "Expenses:Groceries", "Regex": "TESCO"}]}`},
+			[]string{"Expenses:Fuel", "Expenses:Groceries", ""}},
+		{"priority", map[string]string{"config.json": `{"AccountFromDescription": [
+			{"Account": "Expenses:Misc", "Regex": "."},
+			{"Account": "Expenses:Groceries", "Regex": "TESCO", "Priority": 1}]}`},
+			[]string{"Expenses:Groceries", "Expenses:Groceries", "Expenses:Misc"}},
+		{"include", map[string]string{
+			"config.json": `{"Include": ["base.json"], "AccountFromDescription": [
+				{"Account": "Expenses:Fuel", "Regex": "PETROL"}]}`,
+			"base.json": `{"AccountFromDescription": [
+				{"Account": "Expenses:Groceries", "Regex": "TESCO"},
+				{"Account": "Expenses:Misc", "Regex": "."}]}`},
+			[]string{"Expenses:Fuel", "Expenses:Groceries", "Expenses:Misc"}},
+		{"priority of an include", map[string]string{
+			"config.json": `{"Include": ["base.json"], "AccountFromDescription": [
+				{"Account": "Expenses:Fuel", "Regex": "PETROL"}]}`,
+			"base.json": `{"AccountFromDescription": [
+				{"Account": "Expenses:Groceries", "Regex": "TESCO", "Priority": 1}]}`},
+			[]string{"Expenses:Groceries", "Expenses:Groceries", ""}},
+	}
+	for _, test := range tests {
+		got := loadRules(t, test.files, "TESCO PETROL", "TESCO STORES", "COFFEE")
+		for i := range test.want {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
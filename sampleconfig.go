@@ -0,0 +1,145 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Sample config:
+
+// sampleConfigField documents a field of the config with an example value.
+type sampleConfigField struct {
+	doc     string
+	example string // json
+}
+
+// sampleConfigFields has the documentation and examples of the fields of the
+// config, by name.
+var sampleConfigFields = map[string]sampleConfigField{
+	"Include": {
+		"Configs merged before this one, relative to its directory, like [\"common.json\"];\n" +
+			"their rules are tried after the ones of this one.",
+		`[]`,
+	},
+	"SrcAccount": {
+		"Source account used when not given in the arguments.",
+		`"Assets:Checking"`,
+	},
+	"Output": {
+		"Defaults of the command line flags, by their names without the -.",
+		`{"f": "ledger", "output-date-format": "2006/01/02"}`,
+	},
+	"AccountFromDescription": {
+		"Rules that assign accounts to the transactions whose descriptions match\n" +
+			"their regexes; the first matching rule wins, unless another one has a\n" +
+			"higher Priority. Sign only matches debits or credits, After and Before\n" +
+			"limit the dates, and Splits divide the value between accounts.",
+		`[
+  {"Account": "Expenses:Groceries", "Regex": "^TESCO"},
+  {"Account": "Income:Salary", "Regex": "SALARY", "Sign": "credit"},
+  {"Account": "Expenses:Holiday", "Regexes": ["AIRBNB", "RYANAIR"], "After": "2018-06-01", "Before": "2018-07-01", "Priority": 1},
+  {"Regex": "ELECTRIC", "Splits": [
+    {"Account": "Expenses:Electricity", "Amount": "50%"},
+    {"Account": "Expenses:Shared"}
+  ]}
+]`,
+	},
+	"Merchants": {
+		"Clean payee names of the transactions whose descriptions match the regexes.",
+		`[{"Payee": "Local Coffee", "Regex": "^SQ \\*COFFEE"}]`,
+	},
+	"Accounts": {
+		"Valid accounts; the rules that assign other accounts are errors.",
+		`["Expenses:Groceries", "Income:Salary", "Expenses:Holiday", "Expenses:Electricity", "Expenses:Shared",
+  "Expenses:Uncategorized", "Income:Uncategorized"]`,
+	},
+	"AccountTypes": {
+		"Types of the accounts and their subaccounts, to check the signs of the postings:\n" +
+			"asset, liability, equity, expense or income.",
+		`{"Expenses": "expense", "Income": "income"}`,
+	},
+	"OpeningBalances": {
+		"Opening balances of the source accounts, for -verify-balance.",
+		`{"Assets:Checking": "1000.00"}`,
+	},
+	"Aliases": {
+		"Output names of the accounts assigned by the rules.",
+		`{"Expenses:Groceries": "Expenses:Food:Groceries"}`,
+	},
+	"SrcAccounts": {
+		"Source accounts of the account ids of -srcaccount-col and -informat json.",
+		`{"BxBXxLj1m4HMXBm9WZZmCWVbPjX16EHwv99vp": "Assets:Checking"}`,
+	},
+	"DefaultAccount": {
+		"Account of the transactions that no rule matched, when there's no default\n" +
+			"of their sign.",
+		`"Expenses:Uncategorized"`,
+	},
+	"DefaultDebitAccount": {
+		"Account of the debits that no rule matched.",
+		`"Expenses:Uncategorized"`,
+	},
+	"DefaultCreditAccount": {
+		"Account of the credits that no rule matched.",
+		`"Income:Uncategorized"`,
+	},
+	"InputFormat": {
+		"Layout of the inputs when -bank isn't given, for the banks without a\n" +
+			"built-in one, with the columns starting at 0: a single signed\n" +
+			"AmountColumn, or the DebitColumn and CreditColumn, and the optional\n" +
+			"BalanceColumn and CurrencyColumn; the lines starting with the Header\n" +
+			"cells are skipped. Null uses the built-in layouts.",
+		`{"Header": ["Date", "Description", "Amount"], "DateColumn": 0, "DateLayout": "2006-01-02",
+  "DescriptionColumn": 1, "AmountColumn": 2}`,
+	},
+	"AccountOrder": {
+		"Order of the output accounts for -sort-by account, before the unlisted ones.",
+		`["Income:Salary", "Expenses:Food:Groceries"]`,
+	},
+}
+
+// writeSampleConfig writes an example config with all the fields of the
+// config struct, in its order, each one after a // comment with its
+// documentation. The fields missing from sampleConfigFields get their zero
+// values, so that the sample doesn't drift from the struct.
+func writeSampleConfig(out io.Writer) error {
+	var b bytes.Buffer
+	b.WriteString("{\n")
+	t := reflect.TypeOf(config{})
+	first := true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		sample, ok := sampleConfigFields[field.Name]
+		if !ok {
+			dat, err := json.Marshal(reflect.Zero(field.Type).Interface())
+			if err != nil {
+				return err
+			}
+			sample.example = string(dat)
+		}
+		if !first {
+			b.WriteString(",\n")
+		}
+		first = false
+		for _, line := range strings.Split(sample.doc, "\n") {
+			if line != "" {
+				fmt.Fprintf(&b, "  // %s\n", line)
+			}
+		}
+		fmt.Fprintf(&b, "  %q: %s", field.Name, strings.Replace(sample.example, "\n", "\n  ", -1))
+	}
+	b.WriteString("\n}\n")
+	_, err := out.Write(b.Bytes())
+	return err
+}
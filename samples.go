@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Samples:
+
+// checkSamples checks that the rules assign the expected accounts to the
+// descriptions of a csv file with description,account[,value] lines, as
+// unit tests of the config. The unmatched samples get the default accounts
+// of the config, and an empty account expects none. The description is the
+// only field of the samples, for the SearchAll rules, and the value, 1 by
+// default, is the withdrawal for the rules with a Sign. A
+// description,account header is skipped. It prints the samples whose
+// accounts differ and returns their number.
+func checkSamples(out io.Writer, cfg *config, opts *options, fileName string) (int, error) {
+	fd, err := os.Open(filepath.Clean(fileName))
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close() // nolint: errcheck
+	r := csv.NewReader(fd)
+	r.FieldsPerRecord = -1
+	descriptions := newDescriptionCleaner(opts)
+	failed, total := 0, 0
+	for lineNum := 1; ; lineNum++ {
+		line, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return failed, fmt.Errorf("%s: %w", fileName, err)
+		}
+		if len(line) < 2 || len(line) > 3 {
+			return failed, fmt.Errorf("%s:%d: sample with %d columns, expected description,account[,value]", fileName, lineNum, len(line))
+		}
+		if lineNum == 1 && strings.EqualFold(line[0], "description") && strings.EqualFold(line[1], "account") {
+			continue
+		}
+		description := descriptions.clean(line[0])
+		t := transaction{Description: description, Fields: []string{description}, Value: "1"}
+		if len(line) == 3 {
+			t.Value = strings.TrimSpace(line[2])
+		}
+		found, err := cfg.assign(&t)
+		if err != nil {
+			return failed, fmt.Errorf("%s:%d: %w", fileName, lineNum, err)
+		}
+		if !found {
+			t.Account = cfg.defaultAccount(&t)
+		}
+		got := t.Account
+		if len(t.Splits) > 0 {
+			accounts := make([]string, len(t.Splits))
+			for i, sp := range t.Splits {
+				accounts[i] = sp.Account
+			}
+			got = strings.Join(accounts, "+")
+		}
+		total++
+		if expected := strings.TrimSpace(line[1]); got != expected {
+			failed++
+			if _, err := fmt.Fprintf(out, "%s:%d: %s: got %q, expected %q\n", fileName, lineNum, t.Description, got, expected); err != nil {
+				return failed, err
+			}
+		}
+	}
+	_, err = fmt.Fprintf(out, "%d of %d samples failed\n", failed, total)
+	return failed, err
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Selftest:
+
+// selftestStatement is a synthetic aib-debit statement with withdrawals,
+// deposits, several transactions in a day and a split.
+const selftestStatement = `Posted Account, Posted Transactions Date, Description1, Description2, Description3, Debit Amount, Credit Amount, Balance, Posted Currency, Transaction Type
+"123-456",01/03/2018,"TESCO STORES 123","","",12.50,,987.50,EUR,Debit
+"123-456",01/03/2018,"SALARY ACME","","",,1000.00,1987.50,EUR,Credit
+"123-456",02/03/2018,"ELECTRIC IRELAND","","",80.01,,1907.49,EUR,Debit
+"123-456",02/03/2018,"TESCO STORES 456","","",0.99,,1906.50,EUR,Debit
+`
+
+// selftestConfig has the rules that assign all the transactions of
+// selftestStatement.
+var selftestConfig = config{
+	AccountFromDescription: []accountFromDescription{
+		{Account: "Expenses:Groceries", Regex: "^TESCO"},
+		{Account: "Income:Salary", Sign: "credit"},
+		{Regex: "ELECTRIC", Splits: []split{
+			{Account: "Expenses:Electricity", Amount: "50%"},
+			{Account: "Expenses:Shared"},
+		}},
+	},
+}
+
+// selftest runs selftestStatement through the parsing, the rules, the csv
+// output and the parsing of the output back, returning the invariants that
+// failed: unique ids, balanced entries and the same transactions at the end.
+func selftest() []error {
+	opts := options{outputDateFormat: "2006-01-02", legs: "both", informat: "bank", normalizeUnicode: true,
+		collapseNewlines: true, srcAccountColumn: -1, overrides: layoutOverrides{date: -1, description: -1, amount: -1}, centuryPivot: 69}
+	var errs []error
+	failf := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	var parsed []*transaction
+	for r := range parseReader(strings.NewReader(selftestStatement), nil, &opts) {
+		if r.err != nil {
+			return append(errs, fmt.Errorf("parsing the statement: %w", r.err))
+		}
+		parsed = append(parsed, r.t)
+	}
+	if len(parsed) != 4 {
+		failf("parsed %d transactions from the statement, expected 4", len(parsed))
+	}
+	ids := map[string]bool{}
+	for _, t := range parsed {
+		if ids[t.ID] {
+			failf("repeated id %s", t.ID)
+		}
+		ids[t.ID] = true
+	}
+	cfg := selftestConfig
+	for i := range cfg.AccountFromDescription {
+		if err := cfg.AccountFromDescription[i].compile(); err != nil {
+			return append(errs, err)
+		}
+	}
+	var out bytes.Buffer
+	o := newOutputCsvFormat(&opts, &cfg, "Assets:Checking")
+	if err := o.Init(&out); err != nil {
+		return append(errs, fmt.Errorf("writing the output: %w", err))
+	}
+	for _, t := range parsed {
+		t.SrcAccount = "Assets:Checking"
+		matched, err := cfg.assign(t)
+		if err != nil {
+			failf("assigning %s: %v", t.Description, err)
+		} else if !matched {
+			failf("no rule matched %s", t.Description)
+		}
+		if err := o.Add(t); err != nil {
+			return append(errs, fmt.Errorf("writing the output: %w", err))
+		}
+	}
+	if err := o.Finish(); err != nil {
+		return append(errs, fmt.Errorf("writing the output: %w", err))
+	}
+	if err := selftestBalance(out.String()); err != nil {
+		errs = append(errs, err)
+	}
+	opts.informat = "bankcsv"
+	var replayed []*transaction
+	for r := range parseReader(strings.NewReader(out.String()), nil, &opts) {
+		if r.err != nil {
+			return append(errs, fmt.Errorf("reading the output back: %w", r.err))
+		}
+		replayed = append(replayed, r.t)
+	}
+	if len(replayed) != len(parsed) {
+		failf("read %d transactions back from the output, expected %d", len(replayed), len(parsed))
+		return errs
+	}
+	for i, t := range replayed {
+		if t.ID != parsed[i].ID || t.Value != parsed[i].Value || !t.Date.Equal(parsed[i].Date) {
+			failf("transaction %s %s read back as %s %s", parsed[i].ID, parsed[i].Value, t.ID, t.Value)
+		}
+	}
+	return errs
+}
+
+// selftestBalance checks that the withdrawals of the rows of each
+// transaction in the csv output add up to zero.
+func selftestBalance(output string) error {
+	r := csv.NewReader(strings.NewReader(output))
+	if _, err := r.Read(); err != nil {
+		return fmt.Errorf("reading the output header: %w", err)
+	}
+	var id string
+	var sum amount
+	for {
+		line, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("reading the output: %w", err)
+		}
+		if line[0] != "" {
+			if id != "" && !sum.IsZero() {
+				return fmt.Errorf("transaction %s doesn't balance, off by %s", id, sum)
+			}
+			id, sum = line[0], amount{}
+		}
+		value, err := parseAmount(line[3])
+		if err != nil {
+			return fmt.Errorf("transaction %s: %w", id, err)
+		}
+		sum = sum.Add(value)
+	}
+	if id != "" && !sum.IsZero() {
+		return fmt.Errorf("transaction %s doesn't balance, off by %s", id, sum)
+	}
+	return nil
+}
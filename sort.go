@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Sorting:
+
+type sortKey struct {
+	field      string // date, value, account or description
+	descending bool
+}
+
+// parseSortBy parses the comma-separated keys of -sort-by, each one
+// optionally prefixed by - for descending order.
+func parseSortBy(list string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		key := sortKey{field: strings.TrimPrefix(field, "-"), descending: strings.HasPrefix(field, "-")}
+		switch key.field {
+		case "date", "value", "account", "description":
+		default:
+			return nil, fmt.Errorf("unknown key %q, must be date, value, account or description", field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// compare returns the order of the transactions by the key, negative if a
+// comes first. The accounts are in the order of their ranks, the unranked
+// ones coming after the others in string order.
+func (k sortKey) compare(a *transaction, b *transaction, ranks map[string]int) int {
+	c := 0
+	switch k.field {
+	case "date":
+		if a.Date.Before(b.Date) {
+			c = -1
+		} else if a.Date.After(b.Date) {
+			c = 1
+		}
+	case "value":
+		va, erra := parseAmount(a.Value)
+		vb, errb := parseAmount(b.Value)
+		if erra != nil || errb != nil {
+			c = strings.Compare(a.Value, b.Value)
+		} else {
+			c = va.Sub(vb).Sign()
+		}
+	case "account":
+		ra, oka := ranks[a.Account]
+		rb, okb := ranks[b.Account]
+		switch {
+		case oka && okb:
+			c = ra - rb
+		case oka:
+			c = -1
+		case okb:
+			c = 1
+		default:
+			c = strings.Compare(a.Account, b.Account)
+		}
+	case "description":
+		c = strings.Compare(a.Description, b.Description)
+	}
+	if k.descending {
+		return -c
+	}
+	return c
+}
+
+// outputSortFormat buffers the transactions and writes them sorted to
+// another output format at the end. The sort is stable.
+type outputSortFormat struct {
+	outputFormat
+	keys     []sortKey
+	ranks    map[string]int // of the accounts, from the AccountOrder of the config
+	buffered []*transaction
+}
+
+func newOutputSortFormat(o outputFormat, keys []sortKey, accountOrder []string) outputFormat {
+	ranks := map[string]int{}
+	for i, account := range accountOrder {
+		if _, ok := ranks[account]; !ok {
+			ranks[account] = i
+		}
+	}
+	return &outputSortFormat{outputFormat: o, keys: keys, ranks: ranks}
+}
+
+func (o *outputSortFormat) Init(out io.Writer) error {
+	return o.outputFormat.Init(out)
+}
+
+func (o *outputSortFormat) Add(t *transaction) error {
+	o.buffered = append(o.buffered, t)
+	return nil
+}
+
+func (o *outputSortFormat) Finish() error {
+	sort.SliceStable(o.buffered, func(i, j int) bool {
+		for _, key := range o.keys {
+			if c := key.compare(o.buffered[i], o.buffered[j], o.ranks); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	for _, t := range o.buffered {
+		if err := o.outputFormat.Add(t); err != nil {
+			return err
+		}
+	}
+	return o.outputFormat.Finish()
+}
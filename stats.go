@@ -0,0 +1,103 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+// runStats has the metrics of a run, written by -stats-out.
+type runStats struct {
+	Total      int               `json:"total"`
+	Matched    int               `json:"matched"`
+	Unmatched  int               `json:"unmatched"`
+	Skipped    int               `json:"skipped"` // by -since-id, -since-date or -state
+	Duplicates int               `json:"duplicates"`
+	Zero       int               `json:"zero"` // dropped by -drop-zero
+	Accounts   map[string]string `json:"accounts"`
+	FirstDate  string            `json:"first_date,omitempty"`
+	LastDate   string            `json:"last_date,omitempty"`
+	totals     map[string]amount
+	counts     map[string]int // of postings by account
+	first      time.Time
+	last       time.Time
+}
+
+func newRunStats() *runStats {
+	return &runStats{totals: map[string]amount{}, counts: map[string]int{}}
+}
+
+// addDate extends the date range with the date of a processed transaction.
+func (s *runStats) addDate(date time.Time) {
+	if s.first.IsZero() || date.Before(s.first) {
+		s.first = date
+	}
+	if date.After(s.last) {
+		s.last = date
+	}
+}
+
+// addMatched adds the postings of a matched transaction to the totals of
+// their accounts, with the sign of the account balances.
+func (s *runStats) addMatched(t *transaction) {
+	s.Matched++
+	legs := append([]posting{{Account: t.SrcAccount, Value: t.Value}}, t.dstLegs()...)
+	for _, leg := range legs {
+		value, err := parseAmount(leg.Value)
+		if err != nil {
+			warnTransactionf(t, "value %q of %s not added to the stats: %s", leg.Value, leg.Account, err)
+			continue
+		}
+		s.totals[leg.Account] = s.totals[leg.Account].Sub(value)
+		s.counts[leg.Account]++
+	}
+}
+
+func (s *runStats) write(fileName string) error {
+	s.Accounts = map[string]string{}
+	for account, total := range s.totals {
+		s.Accounts[account] = total.String()
+	}
+	if !s.first.IsZero() {
+		s.FirstDate = s.first.Format("2006-01-02")
+		s.LastDate = s.last.Format("2006-01-02")
+	}
+	dat, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append(dat, '\n'), 0600)
+}
+
+// writeRuleStats writes the number of transactions assigned by each rule,
+// from the most used ones to the rules that no longer match anything. The
+// rules with the same number are in the order they are tried.
+func writeRuleStats(out io.Writer, cfg *config) error {
+	order := make([]int, len(cfg.AccountFromDescription))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return cfg.ruleHits[order[a]] > cfg.ruleHits[order[b]] })
+	for _, i := range order {
+		descAcc := &cfg.AccountFromDescription[i]
+		account := descAcc.Account
+		if account == "" {
+			account = "(splits)"
+		}
+		name := descAcc.name()
+		if name == "" {
+			name = "(all)"
+		}
+		if _, err := fmt.Fprintf(out, "%6d  %-30s %s (%s)\n", cfg.ruleHits[i], account, name, descAcc.file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table:
+
+// tableWriter is a recordWriter that buffers all records and writes them on
+// Flush as a table, with each column padded to its widest cell.
+type tableWriter struct {
+	out  io.Writer
+	rows [][]string
+	err  error
+}
+
+func (w *tableWriter) Write(record []string) error {
+	w.rows = append(w.rows, append([]string(nil), record...))
+	return nil
+}
+
+func (w *tableWriter) Flush() {
+	var widths []int
+	for _, row := range w.rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	b := bufio.NewWriter(w.out)
+	for _, row := range w.rows {
+		var line strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			line.WriteString(cell)
+			line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+		if _, err := b.WriteString(strings.TrimRight(line.String(), " ") + "\n"); err != nil {
+			w.err = err
+			return
+		}
+	}
+	w.rows = nil
+	w.err = b.Flush()
+}
+
+func (w *tableWriter) Error() error {
+	return w.err
+}
+
+// outputTableFormat has the columns of the csv format, aligned for review in
+// a terminal. The whole output is buffered.
+type outputTableFormat struct {
+	*outputCsvFormat
+}
+
+func newOutputTableFormat(opts *options, cfg *config, srcAccount string) outputFormat {
+	o := outputTableFormat{newOutputCsvFormat(opts, cfg, srcAccount).(*outputCsvFormat)}
+	o.symbol = opts.symbol
+	if opts.groupOutput {
+		o.group = &opts.numbers
+	}
+	return &o
+}
+
+func (o *outputTableFormat) Init(out io.Writer) error {
+	o.out = out
+	o.outCsv = &tableWriter{out: out}
+	return o.writeHeader()
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Output templates:
+
+// isOutputTemplate checks if the output name has {year} or {month}
+// placeholders.
+func isOutputTemplate(name string) bool {
+	return strings.Contains(name, "{year}") || strings.Contains(name, "{month}")
+}
+
+// expandOutputTemplate replaces the placeholders of the output name with the
+// ones of the date.
+func expandOutputTemplate(name string, date time.Time) string {
+	name = strings.Replace(name, "{year}", fmt.Sprintf("%04d", date.Year()), -1)
+	return strings.Replace(name, "{month}", fmt.Sprintf("%02d", date.Month()), -1)
+}
+
+type outputTemplateTarget struct {
+	file   *outputFile
+	format outputFormat
+}
+
+// outputTemplateFormat writes each transaction to the file given by the
+// expansion of the output name template with its date, in the output format
+// of the template. The files are opened when their first transaction comes.
+type outputTemplateFormat struct {
+	opts       options
+	cfg        *config
+	srcAccount string
+	targets    map[string]*outputTemplateTarget
+	names      []string // of the targets, in opening order
+}
+
+func newOutputTemplateFormat(opts *options, cfg *config, srcAccount string) outputFormat {
+	return &outputTemplateFormat{opts: *opts, cfg: cfg, srcAccount: srcAccount, targets: map[string]*outputTemplateTarget{}}
+}
+
+func (o *outputTemplateFormat) Init(out io.Writer) error {
+	return nil
+}
+
+func (o *outputTemplateFormat) Add(t *transaction) error {
+	name := expandOutputTemplate(o.opts.outputName, t.Date)
+	target, ok := o.targets[name]
+	if !ok {
+		if dir := filepath.Dir(name); dir != "." {
+			if err := os.MkdirAll(dir, 0750); err != nil {
+				return err
+			}
+		}
+		file, err := openOutput(name, o.opts.gzip)
+		if err != nil {
+			return err
+		}
+		target = &outputTemplateTarget{
+			file:   file,
+			format: outputFormats[o.opts.format](&o.opts, o.cfg, o.srcAccount),
+		}
+		o.targets[name] = target
+		o.names = append(o.names, name)
+		if err := target.format.Init(target.file); err != nil {
+			return err
+		}
+		// Only the first file has the opening entry.
+		o.opts.openingBalance = ""
+	}
+	return target.format.Add(t)
+}
+
+func (o *outputTemplateFormat) Finish() error {
+	for _, name := range o.names {
+		if err := o.targets[name].format.Finish(); err != nil {
+			return err
+		}
+		if err := o.targets[name].file.close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Account tree:
+
+// accountNode is an account component in the -tree report, with the number
+// of postings and the total of the account and its subaccounts.
+type accountNode struct {
+	name     string
+	count    int
+	total    amount
+	children map[string]*accountNode
+}
+
+// newAccountTree builds the tree of the accounts that got postings in the
+// run, split by the account separator.
+func newAccountTree(stats *runStats, separator string) *accountNode {
+	root := &accountNode{children: map[string]*accountNode{}}
+	for account, total := range stats.totals {
+		node := root
+		for _, name := range strings.Split(account, separator) {
+			child, ok := node.children[name]
+			if !ok {
+				child = &accountNode{name: name, children: map[string]*accountNode{}}
+				node.children[name] = child
+			}
+			child.count += stats.counts[account]
+			child.total = child.total.Add(total)
+			node = child
+		}
+	}
+	return root
+}
+
+// write prints the subaccounts of the node indented by depth, in name order.
+func (n *accountNode) write(out io.Writer, depth int) error {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := n.children[name]
+		label := strings.Repeat("  ", depth) + name
+		if _, err := fmt.Fprintf(out, "%-40s %6d %12s\n", label, child.count, child.total); err != nil {
+			return err
+		}
+		if err := child.write(out, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
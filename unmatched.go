@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+	"sort"
+)
+
+// unmatchedEntry aggregates the unmatched transactions with a description.
+type unmatchedEntry struct {
+	Description string `json:"description"`
+	Count       int    `json:"count"`
+	Total       string `json:"total"` // of the withdrawals
+	total       amount
+}
+
+// unmatchedReport has the unmatched transactions by description, written
+// by -unmatched-report.
+type unmatchedReport map[string]*unmatchedEntry
+
+func (r unmatchedReport) add(t *transaction) {
+	entry, ok := r[t.Description]
+	if !ok {
+		entry = &unmatchedEntry{Description: t.Description}
+		r[t.Description] = entry
+	}
+	entry.Count++
+	value, err := parseAmount(t.Value)
+	if err != nil {
+		warnTransactionf(t, "value %q not added to the unmatched report: %s", t.Value, err)
+		return
+	}
+	entry.total = entry.total.Add(value)
+}
+
+// sorted returns the entries with the most frequent descriptions first.
+func (r unmatchedReport) sorted() []*unmatchedEntry {
+	entries := make([]*unmatchedEntry, 0, len(r))
+	for _, entry := range r {
+		entry.Total = entry.total.String()
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Description < entries[j].Description
+	})
+	return entries
+}
+
+// write writes the report with the most frequent descriptions first.
+func (r unmatchedReport) write(fileName string) error {
+	dat, err := json.MarshalIndent(r.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append(dat, '\n'), 0600)
+}
+
+// writeConfig writes a skeleton config with a rule for each unmatched
+// description, with an empty Account to be filled in, written by
+// -emit-config-from-unmatched.
+func (r unmatchedReport) writeConfig(fileName string) error {
+	type skeletonRule struct {
+		Account string
+		Regex   string
+	}
+	var skeleton struct {
+		AccountFromDescription []skeletonRule
+	}
+	skeleton.AccountFromDescription = []skeletonRule{}
+	for _, entry := range r.sorted() {
+		regex := "^" + regexp.QuoteMeta(entry.Description) + "$"
+		skeleton.AccountFromDescription = append(skeleton.AccountFromDescription, skeletonRule{Regex: regex})
+	}
+	dat, err := json.MarshalIndent(skeleton, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, append(dat, '\n'), 0600)
+}
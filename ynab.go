@@ -0,0 +1,67 @@
+// Copyright (c) 2018 Leandro Lisboa Penz <lpenz@lpenz.org>
+// This file is subject to the terms and conditions defined in
+// file LICENSE, which is part of this source code package.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// YNAB:
+
+// ynabDateFormat is the date format of the YNAB csv imports.
+const ynabDateFormat = "01/02/2006"
+
+// outputYNABFormat writes the csv imported by YNAB, with a row per
+// transaction of the source account. The values are in separate Outflow
+// and Inflow columns, or in a single Amount column, positive for the
+// deposits, with -ynab-amount.
+type outputYNABFormat struct {
+	outCsv *csv.Writer
+	amount bool
+}
+
+func newOutputYNABFormat(opts *options, cfg *config, srcAccount string) outputFormat {
+	return &outputYNABFormat{amount: opts.ynabAmount}
+}
+
+func (o *outputYNABFormat) Init(out io.Writer) error {
+	o.outCsv = csv.NewWriter(out)
+	header := []string{"Date", "Payee", "Memo", "Outflow", "Inflow"}
+	if o.amount {
+		header = []string{"Date", "Payee", "Memo", "Amount"}
+	}
+	if err := o.outCsv.Write(header); err != nil {
+		return fmt.Errorf("error writing ynab header: %w", err)
+	}
+	return nil
+}
+
+func (o *outputYNABFormat) Add(t *transaction) error {
+	// The payee is the clean name of the Merchants, if any, with the
+	// description in the memo.
+	payee, memo := t.Description, ""
+	if t.Payee != "" {
+		payee, memo = t.Payee, t.Description
+	}
+	record := []string{t.Date.Format(ynabDateFormat), payee, memo}
+	if o.amount {
+		record = append(record, negate(t.Value))
+	} else if strings.HasPrefix(t.Value, "-") {
+		record = append(record, "", negate(t.Value))
+	} else {
+		record = append(record, t.Value, "")
+	}
+	if err := o.outCsv.Write(record); err != nil {
+		return fmt.Errorf("error writing ynab record: %w", err)
+	}
+	return nil
+}
+
+func (o *outputYNABFormat) Finish() error {
+	return o.flush()
+}